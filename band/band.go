@@ -0,0 +1,203 @@
+// Package band knows the amateur radio bands from 160m to 23cm, by their ADIF names, and the dial
+// frequencies WSJT-X uses for its modes on them in each IARU region. Frequencies are in Hz, as in
+// WSJT-X's messages.
+package band
+
+import "strings"
+
+// Band is an amateur radio band, with the edges ADIF gives it.
+type Band struct {
+	// Name is the band's ADIF name, e.g. "20m" or "70cm".
+	Name  string
+	Lower uint64
+	Upper uint64
+}
+
+// Contains reports whether the frequency is within the band, including its edges.
+func (b Band) Contains(hz uint64) bool {
+	return hz >= b.Lower && hz <= b.Upper
+}
+
+func (b Band) String() string {
+	return b.Name
+}
+
+// Bands are the bands from 160m to 23cm, in order of frequency.
+var Bands = []Band{
+	{"160m", 1_800_000, 2_000_000},
+	{"80m", 3_500_000, 4_000_000},
+	{"60m", 5_060_000, 5_450_000},
+	{"40m", 7_000_000, 7_300_000},
+	{"30m", 10_100_000, 10_150_000},
+	{"20m", 14_000_000, 14_350_000},
+	{"17m", 18_068_000, 18_168_000},
+	{"15m", 21_000_000, 21_450_000},
+	{"12m", 24_890_000, 24_990_000},
+	{"10m", 28_000_000, 29_700_000},
+	{"8m", 40_000_000, 45_000_000},
+	{"6m", 50_000_000, 54_000_000},
+	{"5m", 54_000_001, 69_900_000},
+	{"4m", 70_000_000, 71_000_000},
+	{"2m", 144_000_000, 148_000_000},
+	{"1.25m", 222_000_000, 225_000_000},
+	{"70cm", 420_000_000, 450_000_000},
+	{"33cm", 902_000_000, 928_000_000},
+	{"23cm", 1_240_000_000, 1_300_000_000},
+}
+
+// ForFrequency returns the band the frequency is in, if any.
+func ForFrequency(hz uint64) (Band, bool) {
+	for _, b := range Bands {
+		if b.Contains(hz) {
+			return b, true
+		}
+	}
+	return Band{}, false
+}
+
+// Named returns the band with the ADIF name, in either case.
+func Named(name string) (Band, bool) {
+	for _, b := range Bands {
+		if strings.EqualFold(b.Name, name) {
+			return b, true
+		}
+	}
+	return Band{}, false
+}
+
+// Region is an IARU region: 1 is Europe, Africa, the Middle East and northern Asia, 2 the Americas,
+// and 3 the rest of Asia and the Pacific.
+type Region int
+
+const (
+	AllRegions Region = 0
+	Region1    Region = 1
+	Region2    Region = 2
+	Region3    Region = 3
+)
+
+// dialFrequency is a dial frequency WSJT-X uses for a mode, in one region or all of them.
+type dialFrequency struct {
+	mode   string
+	hz     uint64
+	region Region
+}
+
+// dialFrequencies are those in WSJT-X's default frequency list for FT8, FT4, JT65, WSPR and Q65,
+// in order of frequency. Some only apply in one region, where they're used instead of or as well as
+// those for all regions: 4m is only allocated in Region 1, 1.25m and 33cm only in Region 2, and
+// Region 3 has its own FT4 frequency on 80m.
+var dialFrequencies = []dialFrequency{
+	{"WSPR", 1_836_600, AllRegions},
+	{"JT65", 1_838_000, AllRegions},
+	{"FT8", 1_840_000, AllRegions},
+	{"FT4", 3_568_000, Region3},
+	{"WSPR", 3_568_600, AllRegions},
+	{"JT65", 3_570_000, AllRegions},
+	{"FT8", 3_573_000, AllRegions},
+	{"FT4", 3_575_000, AllRegions},
+	{"WSPR", 5_287_200, AllRegions},
+	{"FT8", 5_357_000, AllRegions},
+	{"WSPR", 7_038_600, AllRegions},
+	{"FT4", 7_047_500, AllRegions},
+	{"FT8", 7_074_000, AllRegions},
+	{"JT65", 7_076_000, AllRegions},
+	{"FT8", 10_136_000, AllRegions},
+	{"JT65", 10_138_000, AllRegions},
+	{"WSPR", 10_138_700, AllRegions},
+	{"FT4", 10_140_000, AllRegions},
+	{"FT8", 14_074_000, AllRegions},
+	{"JT65", 14_076_000, AllRegions},
+	{"FT4", 14_080_000, AllRegions},
+	{"WSPR", 14_095_600, AllRegions},
+	{"FT8", 18_100_000, AllRegions},
+	{"JT65", 18_102_000, AllRegions},
+	{"FT4", 18_104_000, AllRegions},
+	{"WSPR", 18_104_600, AllRegions},
+	{"FT8", 21_074_000, AllRegions},
+	{"JT65", 21_076_000, AllRegions},
+	{"WSPR", 21_094_600, AllRegions},
+	{"FT4", 21_140_000, AllRegions},
+	{"FT8", 24_915_000, AllRegions},
+	{"JT65", 24_917_000, AllRegions},
+	{"FT4", 24_919_000, AllRegions},
+	{"WSPR", 24_924_600, AllRegions},
+	{"FT8", 28_074_000, AllRegions},
+	{"JT65", 28_076_000, AllRegions},
+	{"WSPR", 28_124_600, AllRegions},
+	{"FT4", 28_180_000, AllRegions},
+	{"Q65", 50_275_000, AllRegions},
+	{"JT65", 50_276_000, Region2},
+	{"JT65", 50_276_000, Region3},
+	{"WSPR", 50_293_000, Region2},
+	{"WSPR", 50_293_000, Region3},
+	{"JT65", 50_310_000, AllRegions},
+	{"FT8", 50_313_000, AllRegions},
+	{"FT4", 50_318_000, AllRegions},
+	{"FT8", 50_323_000, AllRegions},
+	{"WSPR", 70_091_000, Region1},
+	{"JT65", 70_102_000, Region1},
+	{"FT8", 70_154_000, Region1},
+	{"Q65", 144_116_000, AllRegions},
+	{"JT65", 144_120_000, AllRegions},
+	{"FT4", 144_170_000, AllRegions},
+	{"FT8", 144_174_000, AllRegions},
+	{"WSPR", 144_489_000, AllRegions},
+	{"JT65", 222_065_000, Region2},
+	{"Q65", 222_065_000, Region2},
+	{"JT65", 432_065_000, AllRegions},
+	{"Q65", 432_065_000, AllRegions},
+	{"WSPR", 432_300_000, AllRegions},
+	{"JT65", 902_065_000, Region2},
+	{"Q65", 902_065_000, Region2},
+	{"JT65", 1_296_065_000, AllRegions},
+	{"Q65", 1_296_065_000, AllRegions},
+	{"WSPR", 1_296_500_000, AllRegions},
+}
+
+// DialFrequencies returns the dial frequencies WSJT-X uses for the mode, e.g. "FT8", in the
+// region, in order of frequency. AllRegions gives those used anywhere.
+func DialFrequencies(mode string, region Region) []uint64 {
+	var frequencies []uint64
+	for _, f := range dialFrequencies {
+		if !f.matches(mode, region) {
+			continue
+		}
+		// A frequency used in two of the regions is listed for each.
+		if n := len(frequencies); n == 0 || frequencies[n-1] != f.hz {
+			frequencies = append(frequencies, f.hz)
+		}
+	}
+	return frequencies
+}
+
+// DialFrequency returns the dial frequency WSJT-X uses for the mode on the band in the region. A
+// frequency for the region itself is preferred to one for all regions, e.g. FT4 on 80m is 3.568
+// MHz in Region 3 and 3.575 MHz elsewhere. With AllRegions, a frequency for all regions is
+// preferred, and failing that the lowest for any region on the band is returned.
+func DialFrequency(mode string, b Band, region Region) (uint64, bool) {
+	var hz uint64
+	for _, f := range dialFrequencies {
+		if !f.matches(mode, region) || !b.Contains(f.hz) {
+			continue
+		}
+		if f.region == region {
+			return f.hz, true
+		}
+		if hz == 0 {
+			hz = f.hz
+		}
+	}
+	return hz, hz != 0
+}
+
+func (f dialFrequency) matches(mode string, region Region) bool {
+	return strings.EqualFold(f.mode, mode) &&
+		(region == AllRegions || f.region == AllRegions || f.region == region)
+}
+
+// RF returns the frequency actually on the air for an audio offset from the dial frequency, such
+// as a decode's DeltaFrequencyHz. WSJT-X always works in upper sideband.
+func RF(dial uint64, offset uint32) uint64 {
+	return dial + uint64(offset)
+}
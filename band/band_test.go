@@ -0,0 +1,119 @@
+package band
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestForFrequency(t *testing.T) {
+	tests := []struct {
+		hz   uint64
+		want string
+	}{
+		{1_840_000, "160m"},
+		{3_573_000, "80m"},
+		{7_074_000, "40m"},
+		{14_000_000, "20m"},
+		{14_350_000, "20m"},
+		{50_313_000, "6m"},
+		{54_000_000, "6m"},
+		{54_000_001, "5m"},
+		{144_174_000, "2m"},
+		{222_065_000, "1.25m"},
+		{1_296_065_000, "23cm"},
+		{14_350_001, ""},
+		{137_500, ""},
+		{2_400_000_000, ""},
+	}
+	for _, tt := range tests {
+		b, ok := ForFrequency(tt.hz)
+		if ok != (tt.want != "") || b.Name != tt.want {
+			t.Errorf("ForFrequency(%d) = %v, %v, want %s", tt.hz, b, ok, tt.want)
+		}
+	}
+}
+
+func TestNamed(t *testing.T) {
+	b, ok := Named("70CM")
+	if !ok || b.Lower != 420_000_000 || b.Upper != 450_000_000 {
+		t.Errorf("got %+v, %v", b, ok)
+	}
+	if _, ok := Named("11m"); ok {
+		t.Error("11m isn't an amateur band")
+	}
+}
+
+func TestDialFrequencies(t *testing.T) {
+	tests := []struct {
+		mode   string
+		region Region
+		want   []uint64
+	}{
+		{"JT65", Region1, []uint64{1_838_000, 3_570_000, 7_076_000, 10_138_000, 14_076_000,
+			18_102_000, 21_076_000, 24_917_000, 28_076_000, 50_310_000, 70_102_000, 144_120_000,
+			432_065_000, 1_296_065_000}},
+		{"jt65", Region2, []uint64{1_838_000, 3_570_000, 7_076_000, 10_138_000, 14_076_000,
+			18_102_000, 21_076_000, 24_917_000, 28_076_000, 50_276_000, 50_310_000, 144_120_000,
+			222_065_000, 432_065_000, 902_065_000, 1_296_065_000}},
+		{"JT65", Region3, []uint64{1_838_000, 3_570_000, 7_076_000, 10_138_000, 14_076_000,
+			18_102_000, 21_076_000, 24_917_000, 28_076_000, 50_276_000, 50_310_000, 144_120_000,
+			432_065_000, 1_296_065_000}},
+		{"JT65", AllRegions, []uint64{1_838_000, 3_570_000, 7_076_000, 10_138_000, 14_076_000,
+			18_102_000, 21_076_000, 24_917_000, 28_076_000, 50_276_000, 50_310_000, 70_102_000,
+			144_120_000, 222_065_000, 432_065_000, 902_065_000, 1_296_065_000}},
+		{"MSK144", Region1, nil},
+	}
+	for _, tt := range tests {
+		if got := DialFrequencies(tt.mode, tt.region); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DialFrequencies(%s, %d) = %v, want %v", tt.mode, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestDialFrequency(t *testing.T) {
+	tests := []struct {
+		mode   string
+		band   string
+		region Region
+		want   uint64
+	}{
+		{"FT8", "20m", Region1, 14_074_000},
+		{"FT8", "20m", Region2, 14_074_000},
+		{"FT8", "20m", Region3, 14_074_000},
+		{"FT4", "80m", Region1, 3_575_000},
+		{"FT4", "80m", Region2, 3_575_000},
+		{"FT4", "80m", Region3, 3_568_000},
+		{"FT4", "80m", AllRegions, 3_575_000},
+		{"FT4", "40m", Region1, 7_047_500},
+		{"JT65", "6m", Region1, 50_310_000},
+		{"JT65", "6m", Region2, 50_276_000},
+		{"JT65", "6m", Region3, 50_276_000},
+		{"JT65", "6m", AllRegions, 50_310_000},
+		{"WSPR", "6m", Region1, 0},
+		{"WSPR", "6m", Region2, 50_293_000},
+		{"WSPR", "6m", AllRegions, 50_293_000},
+		{"WSPR", "30m", AllRegions, 10_138_700},
+		{"FT8", "6m", Region2, 50_313_000},
+		{"FT8", "4m", Region1, 70_154_000},
+		{"FT8", "4m", Region2, 0},
+		{"FT8", "4m", Region3, 0},
+		{"Q65", "1.25m", Region2, 222_065_000},
+		{"Q65", "1.25m", Region1, 0},
+		{"Q65", "33cm", Region2, 902_065_000},
+		{"Q65", "33cm", Region3, 0},
+	}
+	for _, tt := range tests {
+		b, _ := Named(tt.band)
+		got, ok := DialFrequency(tt.mode, b, tt.region)
+		if got != tt.want || ok != (tt.want != 0) {
+			t.Errorf("DialFrequency(%s, %s, %d) = %d, %v, want %d", tt.mode, tt.band, tt.region,
+				got, ok, tt.want)
+		}
+	}
+}
+
+func TestRF(t *testing.T) {
+	if got := RF(14_074_000, 1_500); got != 14_075_500 {
+		t.Errorf("got %d", got)
+	}
+}
@@ -0,0 +1,143 @@
+// Package callsign splits amateur radio callsigns into their parts and checks that they're well
+// formed, following the ITU's structure for callsigns and the usual conventions for prefixes and
+// suffixes added to them, e.g. VE3/K0SWE operating in Ontario or K0SWE/P operating portable.
+package callsign
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// InvalidError is returned when a callsign isn't well formed.
+var InvalidError = errors.New("invalid callsign")
+
+// Call is a callsign split into its parts, e.g. PJ4/K1ABC/P into Prefix PJ4, Base K1ABC and
+// Suffix P.
+type Call struct {
+	// Base is the callsign the station was issued, e.g. K1ABC.
+	Base string
+	// Prefix is where the station is operating, if it's outside its own country or call area,
+	// e.g. VE3 or PJ4. It may also be written after the base, as in K1ABC/VE3.
+	Prefix string
+	// Suffix is how the station is operating, e.g. P for portable, M for mobile, MM for maritime
+	// mobile, QRP or R for a rover, or a digit for a different call area, as in K1ABC/4.
+	Suffix string
+}
+
+// suffixes are the letters which may follow a callsign to say how the station is operating.
+// Anything else after a slash is taken to be a prefix.
+var suffixes = map[string]bool{
+	"P":    true, // portable
+	"M":    true, // mobile
+	"MM":   true, // maritime mobile
+	"AM":   true, // aeronautical mobile
+	"QRP":  true, // low power
+	"QRPP": true,
+	"R":    true, // rover
+	"A":    true, // alternate location
+	"B":    true, // beacon
+	"LH":   true, // lighthouse
+}
+
+// Parse splits a callsign into its parts. Letters may be in either case, and are upper case in
+// the Call.
+func Parse(call string) (Call, error) {
+	s := strings.ToUpper(call)
+	first, rest, hasRest := strings.Cut(s, "/")
+	second, third, hasThird := strings.Cut(rest, "/")
+	switch {
+	case !hasRest:
+		if isBase(first) {
+			return Call{Base: first}, nil
+		}
+	case hasThird:
+		if isPrefix(first) && isBase(second) && isSuffix(third) {
+			return Call{Prefix: first, Base: second, Suffix: third}, nil
+		}
+	case isBase(first) && isSuffix(second):
+		return Call{Base: first, Suffix: second}, nil
+	case isPrefix(first) && isBase(second):
+		return Call{Prefix: first, Base: second}, nil
+	case isBase(first) && isPrefix(second):
+		return Call{Prefix: second, Base: first}, nil
+	}
+	return Call{}, fmt.Errorf("%w: %q", InvalidError, call)
+}
+
+// Valid reports whether the callsign is well formed.
+func Valid(call string) bool {
+	_, err := Parse(call)
+	return err == nil
+}
+
+// String writes the callsign in its usual form, e.g. VE3/K0SWE/P.
+func (c Call) String() string {
+	s := c.Base
+	if c.Prefix != "" {
+		s = c.Prefix + "/" + s
+	}
+	if c.Suffix != "" {
+		s += "/" + c.Suffix
+	}
+	return s
+}
+
+// EffectivePrefix is the prefix the station is operating under, as the CQ WPX Contest counts
+// them: the Prefix if there is one, with a 0 added if it has no digit, otherwise the Base up to
+// its last digit, with that digit changed if the Suffix is a call area. For example K0SWE is K0,
+// VE3/K0SWE is VE3, F/K0SWE is F0, K0SWE/4 is K4 and YW18FIFA is YW18.
+func (c Call) EffectivePrefix() string {
+	if c.Prefix != "" {
+		if strings.IndexAny(c.Prefix, "0123456789") < 0 {
+			return c.Prefix + "0"
+		}
+		return c.Prefix
+	}
+	prefix := strings.TrimRight(c.Base, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	if prefix != "" && len(c.Suffix) == 1 && isDigit(c.Suffix[0]) {
+		return prefix[:len(prefix)-1] + c.Suffix
+	}
+	return prefix
+}
+
+// isBase reports whether the word is a callsign as the ITU issues them: a prefix identifying the
+// country, then one or more digits and up to four letters, e.g. K0SWE, 3DA0RU or YW18FIFA.
+func isBase(word string) bool {
+	letters := trailing(word, isLetter)
+	if letters < 1 || letters > 4 {
+		return false
+	}
+	return isPrefix(word[:len(word)-letters]) && isDigit(word[len(word)-letters-1])
+}
+
+// isPrefix reports whether the word could be a prefix: one or two letters, or a digit and one or
+// two letters, followed by up to four digits, e.g. F, VE3, 3D2 or YW18.
+func isPrefix(word string) bool {
+	digits := trailing(word, isDigit)
+	if digits > 4 {
+		return false
+	}
+	head := word[:len(word)-digits]
+	if len(head) > 0 && isDigit(head[0]) {
+		head = head[1:]
+	}
+	return len(head) >= 1 && len(head) <= 2 && trailing(head, isLetter) == len(head)
+}
+
+// isSuffix reports whether the word says how a station is operating, or is a call area digit.
+func isSuffix(word string) bool {
+	return suffixes[word] || len(word) == 1 && isDigit(word[0])
+}
+
+// trailing counts the bytes at the end of the word which are in the class.
+func trailing(word string, in func(byte) bool) int {
+	n := 0
+	for n < len(word) && in(word[len(word)-1-n]) {
+		n++
+	}
+	return n
+}
+
+func isLetter(b byte) bool { return b >= 'A' && b <= 'Z' }
+func isDigit(b byte) bool  { return b >= '0' && b <= '9' }
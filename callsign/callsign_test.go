@@ -0,0 +1,68 @@
+package callsign
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		call   string
+		want   Call
+		prefix string
+	}{
+		{"K0SWE", Call{Base: "K0SWE"}, "K0"},
+		{"k0swe", Call{Base: "K0SWE"}, "K0"},
+		{"W1AW", Call{Base: "W1AW"}, "W1"},
+		{"3DA0RU", Call{Base: "3DA0RU"}, "3DA0"},
+		{"2E0ABC", Call{Base: "2E0ABC"}, "2E0"},
+		{"E51ABC", Call{Base: "E51ABC"}, "E51"},
+		{"YW18FIFA", Call{Base: "YW18FIFA"}, "YW18"},
+		{"VE3/K0SWE", Call{Prefix: "VE3", Base: "K0SWE"}, "VE3"},
+		{"K0SWE/VE3", Call{Prefix: "VE3", Base: "K0SWE"}, "VE3"},
+		{"F/K0SWE", Call{Prefix: "F", Base: "K0SWE"}, "F0"},
+		{"KH1/KH7Z", Call{Prefix: "KH1", Base: "KH7Z"}, "KH1"},
+		{"K0SWE/P", Call{Base: "K0SWE", Suffix: "P"}, "K0"},
+		{"K0SWE/MM", Call{Base: "K0SWE", Suffix: "MM"}, "K0"},
+		{"K0SWE/QRP", Call{Base: "K0SWE", Suffix: "QRP"}, "K0"},
+		{"K0SWE/4", Call{Base: "K0SWE", Suffix: "4"}, "K4"},
+		{"PJ4/K1ABC/P", Call{Prefix: "PJ4", Base: "K1ABC", Suffix: "P"}, "PJ4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.call, func(t *testing.T) {
+			got, err := Parse(tt.call)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if prefix := got.EffectivePrefix(); prefix != tt.prefix {
+				t.Errorf("effective prefix %s, want %s", prefix, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, call := range []string{
+		"", "K0", "KSWE", "0SWE", "K0SWEXY", "-12", "K0SWE/", "/K0SWE", "K0SWE/P/VE3",
+		"K0SWE/XYZZY", "VE3/K0SWE/VE3", "K0-SWE", "<K0SWE>", "ABC1234567",
+	} {
+		if _, err := Parse(call); !errors.Is(err, InvalidError) {
+			t.Errorf("Parse(%q) error = %v", call, err)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, call := range []string{"K0SWE", "VE3/K0SWE", "K0SWE/P", "PJ4/K1ABC/P"} {
+		c, err := Parse(call)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.String(); got != call {
+			t.Errorf("got %s, want %s", got, call)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package wsjtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// defaultHeartbeatInterval is how often WSJT-X sends heartbeats.
+const defaultHeartbeatInterval = 15 * time.Second
+
+// Client speaks the WSJT-X protocol from the other side: it impersonates a WSJT-X instance to a
+// server such as GridTracker or JTAlert. It sends heartbeats while listening, sends the messages
+// WSJT-X would send, and dispatches the messages the server sends back to the handlers registered
+// on the embedded Router as well as to the Listen channel.
+//
+// Like WSJT-X, a Client only acts on messages addressed to its own Id, and talks to the server with
+// the schema negotiated from the server's heartbeat.
+type Client struct {
+	id         string
+	serverAddr *net.UDPAddr
+	conn       *net.UDPConn
+	run        *runState
+	buffers    *datagramBuffers
+	parseMode  ParseMode
+	datagrams  *datagramHandlers
+
+	mu                sync.Mutex
+	schema            uint32
+	version           string
+	revision          string
+	heartbeatInterval time.Duration
+
+	*Router
+}
+
+// MakeClient creates a client with the given Id which talks to a server on the port and address
+// where WSJT-X sends by default.
+func MakeClient(id string) (*Client, error) {
+	return MakeClientGiven(id, net.ParseIP(localhostAddr), wsjtxPort)
+}
+
+// MakeClientGiven creates a client with the given Id which talks to a server on the given address
+// and port. The address may be a multicast group. The client's own socket is bound to an ephemeral
+// port, which is where the server will send its replies.
+func MakeClientGiven(id string, ipAddr net.IP, port uint) (*Client, error) {
+	serverAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%v:%d", ipAddr, port))
+	if err != nil {
+		return nil, err
+	}
+	// Not a connected socket: a server listening on a multicast group replies from its unicast
+	// address, which a connected socket would filter out.
+	conn, err := net.ListenUDP(serverAddr.Network(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(id, serverAddr, conn)
+}
+
+func newClient(id string, serverAddr *net.UDPAddr, conn *net.UDPConn) (*Client, error) {
+	if id == "" {
+		_ = conn.Close()
+		return nil, errors.New("wsjtx client needs an id")
+	}
+	return &Client{
+		id:                id,
+		serverAddr:        serverAddr,
+		conn:              conn,
+		run:               &runState{},
+		buffers:           newDatagramBuffers(MaxDatagramSize),
+		parseMode:         Lenient,
+		datagrams:         &datagramHandlers{},
+		schema:            defaultSchema,
+		heartbeatInterval: defaultHeartbeatInterval,
+		Router:            NewRouter(),
+	}, nil
+}
+
+// Id returns the Id which the client puts in every message.
+func (c *Client) Id() string {
+	return c.id
+}
+
+// LocalAddr returns the address of the client's socket.
+func (c *Client) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// ServerAddr returns the address the client sends to.
+func (c *Client) ServerAddr() net.Addr {
+	return c.serverAddr
+}
+
+// Schema returns the schema the client currently talks to the server with.
+func (c *Client) Schema() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.schema
+}
+
+// SetVersion sets the version and revision advertised in the client's heartbeats.
+func (c *Client) SetVersion(version, revision string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version, c.revision = version, revision
+}
+
+// SetHeartbeatInterval changes how often heartbeats are sent while listening. The default is 15
+// seconds, like WSJT-X; zero sends only the first. It must be called before Listen.
+func (c *Client) SetHeartbeatInterval(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.heartbeatInterval = d
+}
+
+// SetParseMode chooses how strictly messages from the server are parsed. Clients parse leniently
+// by default. It must be called before Listen.
+func (c *Client) SetParseMode(mode ParseMode) {
+	c.parseMode = mode
+}
+
+// Listen sends a heartbeat straight away and then periodically, and listens for messages from the
+// server until the context is cancelled or the client is shut down. Messages addressed to this
+// client's Id are dispatched to the Router's handlers and then placed in the given message
+// channel; others are ignored, as WSJT-X does. A heartbeat from the server sets the schema the
+// client talks with. Errors are reported the same way as by Server.Listen, and channels are
+// treated the same way too.
+func (c *Client) Listen(ctx context.Context, m chan<- Message, e chan<- error) error {
+	if m != nil {
+		defer close(m)
+	}
+	if e != nil {
+		defer close(e)
+	}
+
+	runCtx, finish, err := c.run.start(ctx)
+	if err != nil {
+		c.report(ctx, e, err)
+		return err
+	}
+	defer finish()
+	ctx, stop, err := unblockReads(runCtx, c.conn)
+	if err != nil {
+		c.report(runCtx, e, err)
+		return err
+	}
+	c.mu.Lock()
+	interval := c.heartbeatInterval
+	c.mu.Unlock()
+	heartbeat := func() {
+		if err := c.Heartbeat(); err != nil {
+			c.report(ctx, e, err)
+		}
+	}
+	heartbeat()
+	heartbeating := closedChan
+	if interval > 0 {
+		heartbeating = every(ctx, interval, heartbeat)
+	}
+	defer func() {
+		stop()
+		<-heartbeating
+	}()
+
+	parser := NewParser(c.parseMode)
+	for {
+		b, length, rAddr, err := c.buffers.read(c.conn)
+		if err != nil {
+			c.buffers.put(b)
+			if ctx.Err() != nil || c.run.closed() {
+				return nil
+			}
+			if errors.Is(err, DatagramTruncatedError) {
+				if !c.report(ctx, e, err) {
+					return nil
+				}
+				continue
+			}
+			err = fmt.Errorf("problem reading from wsjtx server: %w", err)
+			c.report(ctx, e, err)
+			return err
+		}
+		message, trailing, err := parser.Parse((*b)[:length])
+		if err != nil && !c.report(ctx, e, err) {
+			c.buffers.put(b)
+			return nil
+		}
+		c.datagrams.dispatch((*b)[:length], rAddr, message, trailing)
+		c.buffers.put(b)
+		if message == nil || message.ClientId() != c.id {
+			continue
+		}
+		if heartbeat, ok := message.(HeartbeatMessage); ok {
+			c.mu.Lock()
+			c.schema = negotiateSchema(heartbeat.MaxSchema, maxSchema)
+			c.mu.Unlock()
+		}
+		c.Dispatch(message)
+		if m == nil {
+			continue
+		}
+		select {
+		case m <- message:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// report passes the error to the OnError handlers and sends it on the errors channel, giving up
+// if the context is done first. It returns false if it gave up.
+func (c *Client) report(ctx context.Context, e chan<- error, err error) bool {
+	c.DispatchError(err)
+	if e == nil {
+		return true
+	}
+	select {
+	case e <- err:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// OnDatagram registers a handler for the raw datagrams the client receives from the server,
+// whichever Id they're addressed to.
+func (c *Client) OnDatagram(h DatagramHandler) {
+	c.datagrams.mu.Lock()
+	defer c.datagrams.mu.Unlock()
+	c.datagrams.handlers = append(c.datagrams.handlers, h)
+}
+
+// Listening returns whether a Listen goroutine is currently running.
+func (c *Client) Listening() bool {
+	return c.run.running()
+}
+
+// Shutdown tells the server that this client is closing, as WSJT-X does when it exits, then stops
+// the listener, if one is running, and closes the client's socket. It waits until the listener has
+// returned or the context is done, whichever comes first. The client can't be used again
+// afterward. Calling Shutdown more than once is harmless.
+func (c *Client) Shutdown(ctx context.Context) error {
+	var err error
+	if !c.run.closed() {
+		err = c.Send(CloseMessage{Id: c.id})
+	}
+	done, first := c.run.stop()
+	if first {
+		if closeErr := c.conn.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Heartbeat sends a heartbeat to the server, advertising the highest schema this library speaks.
+func (c *Client) Heartbeat() error {
+	c.mu.Lock()
+	msg := HeartbeatMessage{Id: c.id, MaxSchema: maxSchema, Version: c.version, Revision: c.revision}
+	c.mu.Unlock()
+	return c.Send(msg)
+}
+
+// Status sends the client's status to the server. The message's Id is set to the client's.
+func (c *Client) Status(msg StatusMessage) error {
+	msg.Id = c.id
+	return c.Send(msg)
+}
+
+// Decode sends a decode to the server. The message's Id is set to the client's.
+func (c *Client) Decode(msg DecodeMessage) error {
+	msg.Id = c.id
+	return c.Send(msg)
+}
+
+// Clear tells the server that the band activity window has been cleared. The message's Id is set
+// to the client's.
+func (c *Client) Clear(msg ClearMessage) error {
+	msg.Id = c.id
+	return c.Send(msg)
+}
+
+// QsoLogged tells the server that a QSO has been logged. The message's Id is set to the client's.
+func (c *Client) QsoLogged(msg QsoLoggedMessage) error {
+	msg.Id = c.id
+	return c.Send(msg)
+}
+
+// WSPRDecode sends a WSPR decode to the server. The message's Id is set to the client's.
+func (c *Client) WSPRDecode(msg WSPRDecodeMessage) error {
+	msg.Id = c.id
+	return c.Send(msg)
+}
+
+// LoggedAdif sends the ADIF record of a logged QSO to the server. The message's Id is set to the
+// client's.
+func (c *Client) LoggedAdif(msg LoggedAdifMessage) error {
+	msg.Id = c.id
+	return c.Send(msg)
+}
+
+// Send encodes the message with the current schema and sends it to the server. The message's Id
+// must be the client's.
+func (c *Client) Send(msg Message) error {
+	if msg.ClientId() != c.id {
+		return fmt.Errorf("message id %q isn't this client's id %q", msg.ClientId(), c.id)
+	}
+	return send(c.conn, c.serverAddr, msg, c.Schema())
+}
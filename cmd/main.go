@@ -2,10 +2,12 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"log"
 	"os"
-	"reflect"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/k0swe/wsjtx-go/v4"
 )
@@ -13,23 +15,39 @@ import (
 // Simple driver binary for wsjtx-go library.
 func main() {
 	log.Println("Listening for WSJT-X...")
-	wsjtxServer, err := wsjtx.MakeServer()
+	// WSJT-X sends a heartbeat every 15 seconds; mark a rig stale after missing 2 and drop it after 4.
+	wsjtxServer, err := wsjtx.NewServer(wsjtx.WithLiveness(15*time.Second, 2, 4))
 	if err != nil {
 		log.Fatalf("%v", err)
 	}
-	wsjtxChannel := make(chan interface{}, 5)
-	errChannel := make(chan error, 5)
-	go wsjtxServer.ListenToWsjtx(wsjtxChannel, errChannel)
+	clientChannel := make(chan wsjtx.ClientEvent, 5)
+	wsjtxServer.WatchClients(clientChannel)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	wsjtxChannel, errChannel := wsjtxServer.Serve(ctx)
 
 	stdinChannel := make(chan string, 5)
 	go stdinCmd(stdinChannel)
 
 	for {
 		select {
-		case err := <-errChannel:
+		case err, ok := <-errChannel:
+			if !ok {
+				errChannel = nil
+				continue
+			}
 			log.Printf("error: %v", err)
-		case message := <-wsjtxChannel:
+		case message, ok := <-wsjtxChannel:
+			if !ok {
+				log.Println("Shutting down")
+				if err := wsjtxServer.Shutdown(context.Background()); err != nil {
+					log.Println(err)
+				}
+				return
+			}
 			handleServerMessage(message)
+		case event := <-clientChannel:
+			log.Printf("Client %s: %s at %v", event.Type, event.Client.Id, event.Client.Addr)
 		case command := <-stdinChannel:
 			command = strings.ToLower(command)
 			handleCommand(command, wsjtxServer)
@@ -53,39 +71,20 @@ func stdinCmd(c chan string) {
 }
 
 // When we receive WSJT-X messages, display them.
-func handleServerMessage(message interface{}) {
-	switch message.(type) {
-	case wsjtx.HeartbeatMessage:
-		log.Println("Heartbeat:", message)
-	case wsjtx.StatusMessage:
-		log.Println("Status:", message)
-	case wsjtx.DecodeMessage:
-		log.Println("Decode:", message)
-	case wsjtx.ClearMessage:
-		log.Println("Clear:", message)
-	case wsjtx.QsoLoggedMessage:
-		log.Println("QSO Logged:", message)
-	case wsjtx.CloseMessage:
-		log.Println("Close:", message)
-	case wsjtx.WSPRDecodeMessage:
-		log.Println("WSPR Decode:", message)
-	case wsjtx.LoggedAdifMessage:
-		log.Println("Logged Adif:", message)
-	default:
-		log.Println("Other:", reflect.TypeOf(message), message)
-	}
+func handleServerMessage(message wsjtx.Message) {
+	log.Printf("%s from %s: %v", message.Type(), message.ClientId(), message)
 }
 
 // When we get a command from stdin, send WSJT-X a message.
-func handleCommand(command string, wsjtxServer wsjtx.Server) {
+func handleCommand(command string, wsjtxServer *wsjtx.Server) {
 	var err error
 	switch command {
 
 	case "hb":
 		log.Println("Sending Heartbeat")
 		err = wsjtxServer.Heartbeat(wsjtx.HeartbeatMessage{
-			Id:        "wsjtx-go",
-			MaxSchema: 2,
+			Id:        "WSJT-X",
+			MaxSchema: 3,
 			Version:   "0.3.1",
 			Revision:  "e0d45c929",
 		})
@@ -96,12 +95,18 @@ func handleCommand(command string, wsjtxServer wsjtx.Server) {
 
 	case "close":
 		log.Println("Sending Close")
-		err = wsjtxServer.Close(wsjtx.CloseMessage{Id: "WSJT-X"})
+		err = wsjtxServer.CloseClient(wsjtx.CloseMessage{Id: "WSJT-X"})
 
 	case "replay":
 		log.Println("Sending Replay")
 		err = wsjtxServer.Replay(wsjtx.ReplayMessage{Id: "WSJT-X"})
 
+	case "clients":
+		for _, client := range wsjtxServer.Clients() {
+			log.Printf("%s %s (%s) at %v, last seen %v", client.Id, client.Version,
+				client.Revision, client.Addr, client.LastSeen)
+		}
+
 	}
 	if err != nil {
 		log.Println(err)
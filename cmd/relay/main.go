@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/k0swe/wsjtx-go/v4"
+	"github.com/k0swe/wsjtx-go/v4/relay"
+)
+
+// destinations collects the -to flags.
+type destinations []relay.Destination
+
+func (d *destinations) String() string {
+	names := make([]string, len(*d))
+	for i, dest := range *d {
+		names[i] = dest.Name
+	}
+	return strings.Join(names, " ")
+}
+
+// Set parses host:port, optionally followed by = and a comma separated list of the message types
+// the destination may send to WSJT-X, e.g. 127.0.0.1:2238=Reply,HighlightCallsign.
+func (d *destinations) Set(value string) error {
+	addrStr, allowStr, hasAllow := strings.Cut(value, "=")
+	addr, err := net.ResolveUDPAddr("udp", addrStr)
+	if err != nil {
+		return err
+	}
+	dest := relay.Destination{Name: addrStr, Addr: addr}
+	if hasAllow {
+		dest.Allow = strings.Split(allowStr, ",")
+	}
+	*d = append(*d, dest)
+	return nil
+}
+
+// Relay between WSJT-X and several servers. Point WSJT-X at the relay's address, and move the
+// servers to other ports, e.g.
+//
+//	go run ./cmd/relay -to 127.0.0.1:2238=Reply,HighlightCallsign -to 127.0.0.1:2239
+func main() {
+	var to destinations
+	addr := flag.String("addr", "127.0.0.1", "address to listen for WSJT-X on")
+	port := flag.Uint("port", 2237, "port to listen for WSJT-X on")
+	flag.Var(&to, "to",
+		"server to relay to, as host:port[=Type,Type...] listing the message types it may send to "+
+			"WSJT-X, or * for all; may be repeated")
+	flag.Parse()
+	if len(to) == 0 {
+		fmt.Fprintln(os.Stderr, "at least one -to is needed")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP(*addr)), wsjtx.WithPort(*port))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	defer server.Shutdown(context.Background())
+	server.OnError(func(err error) { log.Printf("error: %v", err) })
+	server.WatchClients(logClients())
+
+	r, err := relay.New(server, to)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	r.OnError(func(err error) { log.Printf("relay error: %v", err) })
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	log.Printf("Relaying WSJT-X on %v to %v", server.LocalAddr(), to.String())
+	if err := r.Run(ctx); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// logClients logs WSJT-X instances coming and going.
+func logClients() chan<- wsjtx.ClientEvent {
+	events := make(chan wsjtx.ClientEvent, 5)
+	go func() {
+		for event := range events {
+			log.Printf("Client %s: %s at %v", event.Type, event.Client.Id, event.Client.Addr)
+		}
+	}()
+	return events
+}
@@ -0,0 +1,68 @@
+package wsjtx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// MaxDatagramSize is the most a UDP datagram can carry, and so the largest message WSJT-X can send,
+// e.g. a LoggedAdif message with long comments.
+const MaxDatagramSize = 65535
+
+// DatagramTruncatedError is reported when a datagram doesn't fit in the buffer it's read into, as
+// set with WithDatagramSize. The datagram is dropped rather than parsed in part.
+var DatagramTruncatedError = errors.New("wsjtx datagram truncated")
+
+// datagramBuffers pools the buffers datagrams are read into, so that listening doesn't allocate
+// one per datagram. Each buffer has a spare byte: a datagram which fills it was too big and has
+// been truncated by the read.
+type datagramBuffers struct {
+	size int
+	pool sync.Pool
+}
+
+func newDatagramBuffers(size int) *datagramBuffers {
+	d := &datagramBuffers{size: size}
+	d.pool.New = func() interface{} {
+		b := make([]byte, size+1)
+		return &b
+	}
+	return d
+}
+
+// read reads one datagram from the connection into a pooled buffer, which must be given back with
+// put once the datagram is no longer needed, even if there's an error.
+func (d *datagramBuffers) read(conn *net.UDPConn) (*[]byte, int, *net.UDPAddr, error) {
+	b := d.pool.Get().(*[]byte)
+	length, addr, err := conn.ReadFromUDP(*b)
+	if (err == nil && length > d.size) || isMessageTooBig(err) {
+		err = fmt.Errorf("%w: more than %d bytes from %v", DatagramTruncatedError, d.size, addr)
+	}
+	return b, length, addr, err
+}
+
+func (d *datagramBuffers) put(b *[]byte) {
+	d.pool.Put(b)
+}
+
+// sendBuffers pools the buffers messages are encoded into to be sent.
+var sendBuffers = sync.Pool{New: func() interface{} {
+	b := make([]byte, 0, 1024)
+	return &b
+}}
+
+// send encodes the message with the given schema into a pooled buffer and sends it to addr.
+func send(conn *net.UDPConn, addr net.Addr, msg Message, schema uint32) error {
+	b := sendBuffers.Get().(*[]byte)
+	defer sendBuffers.Put(b)
+	datagram, err := encode((*b)[:0], msg, schema)
+	// Keep the buffer if encoding had to grow it.
+	*b = datagram[:0]
+	if err != nil {
+		return err
+	}
+	_, err = conn.WriteTo(datagram, addr)
+	return err
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package wsjtx
+
+// isMessageTooBig reports whether a read failed because the datagram didn't fit. Elsewhere than
+// Windows the read succeeds, filling the buffer, instead.
+func isMessageTooBig(err error) bool {
+	return false
+}
@@ -0,0 +1,14 @@
+package wsjtx
+
+import (
+	"errors"
+	"syscall"
+)
+
+// wsaEMsgSize is WSAEMSGSIZE, which Windows returns instead of a short read when a datagram is
+// bigger than the buffer it's read into.
+const wsaEMsgSize = syscall.Errno(10040)
+
+func isMessageTooBig(err error) bool {
+	return errors.Is(err, wsaEMsgSize)
+}
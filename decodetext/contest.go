@@ -0,0 +1,164 @@
+package decodetext
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+// Exchange is what's sent in a contest exchange, other than the grid, which is in Message.Grid.
+// Only the fields the contest uses are set.
+type Exchange struct {
+	// Roger acknowledges the other station's exchange, as the R in "W1AW K0SWE R DM79".
+	Roger bool
+	// RST is the signal report, e.g. 59 in the EU VHF Contest or 579 in RTTY Roundup.
+	RST int
+	// Serial is the QSO's serial number, in the EU VHF Contest and from DX stations in RTTY
+	// Roundup.
+	Serial int
+	// Class is the ARRL Field Day class: transmitter count and category, e.g. "2A".
+	Class string
+	// Section is the ARRL or RAC section in ARRL Field Day, e.g. "EMA", or "DX".
+	Section string
+	// State is the US state or Canadian province or territory in RTTY Roundup, e.g. "MA".
+	State string
+}
+
+// ParseInMode works out what a decode's text means while WSJT-X is in the given special operation
+// mode, as StatusMessage.SpecialOperationMode gives it. Contest exchanges are understood, as are
+// the Fox's messages which end one QSO and continue another; anything else is parsed as Parse
+// does.
+func ParseInMode(text string, mode wsjtx.SpecialOperationMode) Message {
+	fields := strings.Fields(strings.ToUpper(text))
+	switch mode {
+	case wsjtx.SpecialOperationNAVHF, wsjtx.SpecialOperationWWDigi,
+		wsjtx.SpecialOperationARRLDigi:
+		if m, ok := parseExchange(fields, text, parseGridExchange); ok {
+			return m
+		}
+	case wsjtx.SpecialOperationEUVHF:
+		if m, ok := parseExchange(fields, text, parseEUVHFExchange); ok {
+			return m
+		}
+	case wsjtx.SpecialOperationFieldDay:
+		if m, ok := parseExchange(fields, text, parseFieldDayExchange); ok {
+			return m
+		}
+	case wsjtx.SpecialOperationRTTYRoundup:
+		// "TU;" thanks the last station before calling the next.
+		if len(fields) > 0 && fields[0] == "TU;" {
+			fields = fields[1:]
+		}
+		if m, ok := parseExchange(fields, text, parseRoundupExchange); ok {
+			return m
+		}
+	case wsjtx.SpecialOperationFox, wsjtx.SpecialOperationHound:
+		if len(fields) > 2 && fields[1] == "RR73;" {
+			completed, _, ok := parseCall(fields[0])
+			if m := Parse(strings.Join(fields[2:], " ")); ok && m.Step != FreeText {
+				m.Completed, m.Text = completed, text
+				return m
+			}
+		}
+	}
+	return Parse(text)
+}
+
+// parseExchange parses two callsigns followed by an optional R and the exchange, which is parsed
+// by the contest's own function.
+func parseExchange(
+	fields []string, text string, parse func([]string, *Message) bool) (Message, bool) {
+	if len(fields) < 3 {
+		return Message{}, false
+	}
+	m := Message{Step: ContestExchange, Text: text}
+	var ok bool
+	if m.DxCall, m.DxHashed, ok = parseCall(fields[0]); !ok {
+		return Message{}, false
+	}
+	if m.DeCall, m.DeHashed, ok = parseCall(fields[1]); !ok {
+		return Message{}, false
+	}
+	fields = fields[2:]
+	if fields[0] == "R" {
+		m.Exchange.Roger, fields = true, fields[1:]
+	}
+	if !parse(fields, &m) {
+		return Message{}, false
+	}
+	return m, true
+}
+
+// parseGridExchange parses a 4 character grid, the exchange in the NA VHF, WW Digi and ARRL
+// Digital contests.
+func parseGridExchange(fields []string, m *Message) bool {
+	if len(fields) != 1 || !isGrid(fields[0]) {
+		return false
+	}
+	m.Grid = fields[0]
+	return true
+}
+
+// parseEUVHFExchange parses the EU VHF Contest's exchange: six digits, the RST and a serial
+// number, and a 6 character grid, e.g. "590003 IO91NP".
+func parseEUVHFExchange(fields []string, m *Message) bool {
+	if len(fields) != 2 || len(fields[0]) != 6 || !allIn(fields[0], '0', '9') ||
+		!isSubsquare(fields[1]) {
+		return false
+	}
+	m.Exchange.RST, _ = strconv.Atoi(fields[0][:2])
+	m.Exchange.Serial, _ = strconv.Atoi(fields[0][2:])
+	m.Grid = fields[1]
+	return true
+}
+
+// parseFieldDayExchange parses ARRL Field Day's exchange: the class, from 1 to 32 transmitters and
+// a category from A to F, and the section, e.g. "2A EMA".
+func parseFieldDayExchange(fields []string, m *Message) bool {
+	if len(fields) != 2 || len(fields[0]) < 2 || len(fields[0]) > 3 {
+		return false
+	}
+	class := fields[0]
+	transmitters, err := strconv.Atoi(class[:len(class)-1])
+	category := class[len(class)-1]
+	if err != nil || transmitters < 1 || transmitters > 32 || category < 'A' || category > 'F' ||
+		!isSection(fields[1]) {
+		return false
+	}
+	m.Exchange.Class, m.Exchange.Section = class, fields[1]
+	return true
+}
+
+// parseRoundupExchange parses RTTY Roundup's exchange: an RST from 529 to 599 and either a state
+// or province, or a serial number from DX stations, e.g. "579 MA" or "559 0013".
+func parseRoundupExchange(fields []string, m *Message) bool {
+	if len(fields) != 2 {
+		return false
+	}
+	rst, location := fields[0], fields[1]
+	if len(rst) != 3 || rst[0] != '5' || rst[1] < '2' || rst[1] > '9' || rst[2] != '9' {
+		return false
+	}
+	m.Exchange.RST, _ = strconv.Atoi(rst)
+	switch {
+	case len(location) >= 1 && len(location) <= 4 && allIn(location, '0', '9'):
+		m.Exchange.Serial, _ = strconv.Atoi(location)
+	case isSection(location):
+		m.Exchange.State = location
+	default:
+		return false
+	}
+	return true
+}
+
+// isSubsquare reports whether the word is a 6 character Maidenhead locator.
+func isSubsquare(word string) bool {
+	return len(word) == 6 && isGrid(word[:4]) && allIn(word[4:], 'A', 'X')
+}
+
+// isSection reports whether the word could be an ARRL or RAC section, state or province: two or
+// three letters.
+func isSection(word string) bool {
+	return len(word) >= 2 && len(word) <= 3 && allIn(word, 'A', 'Z')
+}
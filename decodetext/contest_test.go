@@ -0,0 +1,64 @@
+package decodetext
+
+import (
+	"testing"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+func TestParseInMode(t *testing.T) {
+	tests := []struct {
+		text string
+		mode wsjtx.SpecialOperationMode
+		want Message
+	}{
+		{"W9XYZ K1ABC EN37", wsjtx.SpecialOperationNAVHF,
+			Message{Step: ContestExchange, DxCall: "W9XYZ", DeCall: "K1ABC", Grid: "EN37"}},
+		{"K1ABC W9XYZ R FN42", wsjtx.SpecialOperationNAVHF,
+			Message{Step: ContestExchange, DxCall: "K1ABC", DeCall: "W9XYZ", Grid: "FN42",
+				Exchange: Exchange{Roger: true}}},
+		{"K1ABC W9XYZ R FN42", wsjtx.SpecialOperationWWDigi,
+			Message{Step: ContestExchange, DxCall: "K1ABC", DeCall: "W9XYZ", Grid: "FN42",
+				Exchange: Exchange{Roger: true}}},
+		{"K1ABC W9XYZ RR73", wsjtx.SpecialOperationARRLDigi,
+			Message{Step: RR73, DxCall: "K1ABC", DeCall: "W9XYZ"}},
+		{"PA3XYZ/P GM4ABC/P R 590003 IO91NP", wsjtx.SpecialOperationEUVHF,
+			Message{Step: ContestExchange, DxCall: "PA3XYZ/P", DeCall: "GM4ABC/P", Grid: "IO91NP",
+				Exchange: Exchange{Roger: true, RST: 59, Serial: 3}}},
+		{"<PA3XYZ> G4ABC 570123 io91np", wsjtx.SpecialOperationEUVHF,
+			Message{Step: ContestExchange, DxCall: "PA3XYZ", DxHashed: true, DeCall: "G4ABC",
+				Grid: "IO91NP", Exchange: Exchange{RST: 57, Serial: 123}}},
+		{"W9XYZ K1ABC 2A EMA", wsjtx.SpecialOperationFieldDay,
+			Message{Step: ContestExchange, DxCall: "W9XYZ", DeCall: "K1ABC",
+				Exchange: Exchange{Class: "2A", Section: "EMA"}}},
+		{"K1ABC W9XYZ R 16F IL", wsjtx.SpecialOperationFieldDay,
+			Message{Step: ContestExchange, DxCall: "K1ABC", DeCall: "W9XYZ",
+				Exchange: Exchange{Roger: true, Class: "16F", Section: "IL"}}},
+		{"W9XYZ K1ABC 33A EMA", wsjtx.SpecialOperationFieldDay, Message{Step: FreeText}},
+		{"W9XYZ K1ABC 2G EMA", wsjtx.SpecialOperationFieldDay, Message{Step: FreeText}},
+		{"W9XYZ K1ABC 579 MA", wsjtx.SpecialOperationRTTYRoundup,
+			Message{Step: ContestExchange, DxCall: "W9XYZ", DeCall: "K1ABC",
+				Exchange: Exchange{RST: 579, State: "MA"}}},
+		{"TU; K1ABC G3AAA R 559 0013", wsjtx.SpecialOperationRTTYRoundup,
+			Message{Step: ContestExchange, DxCall: "K1ABC", DeCall: "G3AAA",
+				Exchange: Exchange{Roger: true, RST: 559, Serial: 13}}},
+		{"W9XYZ K1ABC 519 MA", wsjtx.SpecialOperationRTTYRoundup, Message{Step: FreeText}},
+		{"K1ABC RR73; W9XYZ <KH1/KH7Z> -08", wsjtx.SpecialOperationHound,
+			Message{Step: Report, DxCall: "W9XYZ", DeCall: "KH1/KH7Z", DeHashed: true, Report: -8,
+				Completed: "K1ABC"}},
+		{"W9XYZ KH1/KH7Z RR73", wsjtx.SpecialOperationFox,
+			Message{Step: RR73, DxCall: "W9XYZ", DeCall: "KH1/KH7Z"}},
+		{"CQ FD K1ABC FN42", wsjtx.SpecialOperationFieldDay,
+			Message{Step: CQ, DeCall: "K1ABC", Directed: "FD", Grid: "FN42"}},
+		{"W9XYZ K1ABC 2A EMA", wsjtx.SpecialOperationNone, Message{Step: FreeText}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String()+" "+tt.text, func(t *testing.T) {
+			tt.want.Text = tt.text
+			if got := ParseInMode(tt.text, tt.mode); got != tt.want {
+				t.Errorf("ParseInMode(%q, %v)\n got  %+v\n want %+v",
+					tt.text, tt.mode, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+// Package decodetext understands the text of WSJT-X decodes, such as DecodeMessage.Message, in
+// the standard FT8 and FT4 formats: calling CQ, and the steps of a QSO from exchanging grids and
+// signal reports to signing with 73. Anything else is free text. ParseInMode also understands the
+// exchanges of the contests WSJT-X supports, and the Fox's messages in DXpedition mode.
+package decodetext
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/k0swe/wsjtx-go/v4/callsign"
+)
+
+// Step is the part a message plays in a QSO.
+type Step int
+
+const (
+	// FreeText is anything which isn't a standard message, e.g. "TNX BOB 73 GL".
+	FreeText Step = iota
+	// CQ calls anyone, e.g. "CQ K0SWE DM79", or anyone answering a directed CQ, e.g.
+	// "CQ POTA K0SWE DM79".
+	CQ
+	// Call is a station calling another without an exchange, e.g. "W1AW K0SWE".
+	Call
+	// Grid answers a CQ with the caller's grid, e.g. "W1AW K0SWE DM79".
+	Grid
+	// Report sends a signal report, e.g. "W1AW K0SWE -12".
+	Report
+	// RogerReport acknowledges a report and sends one back, e.g. "W1AW K0SWE R-12".
+	RogerReport
+	// RRR acknowledges a report, e.g. "W1AW K0SWE RRR".
+	RRR
+	// RR73 acknowledges a report and signs off at once, e.g. "W1AW K0SWE RR73".
+	RR73
+	// SeventyThree signs off, e.g. "W1AW K0SWE 73".
+	SeventyThree
+	// ContestExchange sends the exchange of a contest, as understood by ParseInMode, e.g.
+	// "W1AW K0SWE R 2A CO" in ARRL Field Day.
+	ContestExchange
+)
+
+var stepNames = map[Step]string{
+	FreeText:        "FreeText",
+	CQ:              "CQ",
+	Call:            "Call",
+	Grid:            "Grid",
+	Report:          "Report",
+	RogerReport:     "RogerReport",
+	RRR:             "RRR",
+	RR73:            "RR73",
+	SeventyThree:    "73",
+	ContestExchange: "ContestExchange",
+}
+
+func (s Step) String() string {
+	if name, ok := stepNames[s]; ok {
+		return name
+	}
+	return "Step(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Message is the meaning of a decode's text. Callsigns are given without the angle brackets which
+// show that they were sent as a hash; DeHashed and DxHashed say so instead. A hash which WSJT-X
+// couldn't resolve, shown as <...>, leaves the callsign empty. Compound and non-standard
+// callsigns, such as PJ4/K1ABC or K1ABC/P, are kept whole.
+type Message struct {
+	Step Step
+	// DeCall is the station which sent the message.
+	DeCall   string
+	DeHashed bool
+	// DxCall is the station the message is addressed to, empty for a CQ.
+	DxCall   string
+	DxHashed bool
+	// Directed is what a CQ is directed to, e.g. "DX", "POTA" or "NA", or a frequency such as
+	// "290" for answers in kHz above the band edge. It's empty for a plain CQ.
+	Directed string
+	// Grid is the sender's Maidenhead locator, if given: 4 characters, or 6 in an EU VHF Contest
+	// exchange.
+	Grid string
+	// Report is the signal report in dB, for the Report and RogerReport steps.
+	Report int
+	// Exchange is the rest of a contest exchange, for the ContestExchange step.
+	Exchange Exchange
+	// Completed is the station whose QSO a Fox ended with RR73 in the same message, e.g. "K1ABC"
+	// in "K1ABC RR73; W9XYZ <KH1/KH7Z> -08".
+	Completed string
+	// Text is the decode's text, unchanged.
+	Text string
+}
+
+// DeCallParts splits DeCall into its base call, prefix and suffix. Decodes may carry callsigns
+// which aren't well formed by the ITU's rules, such as special event calls with long suffixes;
+// those fail with a callsign.InvalidError, although the message is still understood.
+func (m Message) DeCallParts() (callsign.Call, error) {
+	return callsign.Parse(m.DeCall)
+}
+
+// DxCallParts splits DxCall into its base call, prefix and suffix, as DeCallParts does DeCall.
+func (m Message) DxCallParts() (callsign.Call, error) {
+	return callsign.Parse(m.DxCall)
+}
+
+// Parse works out what a decode's text means. It never fails: text which isn't a standard message
+// is FreeText.
+func Parse(text string) Message {
+	free := Message{Step: FreeText, Text: text}
+	fields := strings.Fields(strings.ToUpper(text))
+	if len(fields) == 0 {
+		return free
+	}
+	if fields[0] == "CQ" || fields[0] == "QRZ" {
+		return parseCQ(fields[1:], free)
+	}
+	if len(fields) < 2 || len(fields) > 3 {
+		return free
+	}
+	m := free
+	var ok bool
+	if m.DxCall, m.DxHashed, ok = parseCall(fields[0]); !ok {
+		return free
+	}
+	if m.DeCall, m.DeHashed, ok = parseCall(fields[1]); !ok {
+		return free
+	}
+	if len(fields) == 2 {
+		m.Step = Call
+		return m
+	}
+	switch word := fields[2]; {
+	case word == "RRR":
+		m.Step = RRR
+	case word == "RR73":
+		m.Step = RR73
+	case word == "73":
+		m.Step = SeventyThree
+	case isGrid(word):
+		m.Step, m.Grid = Grid, word
+	case isReport(word):
+		m.Step = Report
+		m.Report, _ = strconv.Atoi(word)
+	case len(word) > 1 && word[0] == 'R' && isReport(word[1:]):
+		m.Step = RogerReport
+		m.Report, _ = strconv.Atoi(word[1:])
+	default:
+		return free
+	}
+	return m
+}
+
+// parseCQ parses what follows CQ: an optional direction, the caller and an optional grid.
+func parseCQ(fields []string, free Message) Message {
+	m := free
+	m.Step = CQ
+	if len(fields) > 1 && isDirection(fields[0]) {
+		m.Directed, fields = fields[0], fields[1:]
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		return free
+	}
+	var ok bool
+	if m.DeCall, m.DeHashed, ok = parseCall(fields[0]); !ok {
+		return free
+	}
+	if len(fields) == 2 {
+		if !isGrid(fields[1]) {
+			return free
+		}
+		m.Grid = fields[1]
+	}
+	return m
+}
+
+// parseCall parses a callsign, which may be in angle brackets if it was sent as a hash.
+func parseCall(word string) (call string, hashed bool, ok bool) {
+	if strings.HasPrefix(word, "<") && strings.HasSuffix(word, ">") && len(word) > 2 {
+		call = word[1 : len(word)-1]
+		if call == "..." {
+			return "", true, true
+		}
+		return call, true, looksLikeCall(call)
+	}
+	return word, false, looksLikeCall(word)
+}
+
+// looksLikeCall reports whether the word could be a callsign: letters, digits and slashes, with
+// at least one letter and one digit in a part of it.
+func looksLikeCall(word string) bool {
+	if len(word) < 3 || len(word) > 13 {
+		return false
+	}
+	letter, digit := false, false
+	for _, r := range word {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			letter = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r == '/':
+		default:
+			return false
+		}
+	}
+	return letter && digit && !isReport(word) && !strings.HasPrefix(word, "/") &&
+		!strings.HasSuffix(word, "/")
+}
+
+// isDirection reports whether the word directs a CQ: up to four letters, or three digits giving
+// a frequency.
+func isDirection(word string) bool {
+	if len(word) == 3 && allIn(word, '0', '9') {
+		return true
+	}
+	return len(word) >= 1 && len(word) <= 4 && allIn(word, 'A', 'Z')
+}
+
+// isGrid reports whether the word is a 4 character Maidenhead locator. RR73 looks like one, but
+// WSJT-X always means the acknowledgement by it.
+func isGrid(word string) bool {
+	return len(word) == 4 && word != "RR73" &&
+		allIn(word[:2], 'A', 'R') && allIn(word[2:], '0', '9')
+}
+
+// isReport reports whether the word is a signal report: a sign and two digits, e.g. -05 or +12.
+func isReport(word string) bool {
+	return len(word) == 3 && (word[0] == '-' || word[0] == '+') && allIn(word[1:], '0', '9')
+}
+
+func allIn(word string, lo, hi byte) bool {
+	for i := 0; i < len(word); i++ {
+		if word[i] < lo || word[i] > hi {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package decodetext
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/k0swe/wsjtx-go/v4/callsign"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want Message
+	}{
+		{"CQ K0SWE DM79", Message{Step: CQ, DeCall: "K0SWE", Grid: "DM79"}},
+		{"CQ K0SWE", Message{Step: CQ, DeCall: "K0SWE"}},
+		{"CQ DX K0SWE DM79", Message{Step: CQ, DeCall: "K0SWE", Directed: "DX", Grid: "DM79"}},
+		{"CQ POTA K0SWE DM79", Message{Step: CQ, DeCall: "K0SWE", Directed: "POTA", Grid: "DM79"}},
+		{"CQ NA K0SWE", Message{Step: CQ, DeCall: "K0SWE", Directed: "NA"}},
+		{"CQ 290 K0SWE DM79", Message{Step: CQ, DeCall: "K0SWE", Directed: "290", Grid: "DM79"}},
+		{"QRZ K0SWE DM79", Message{Step: CQ, DeCall: "K0SWE", Grid: "DM79"}},
+		{"CQ PJ4/K1ABC", Message{Step: CQ, DeCall: "PJ4/K1ABC"}},
+		{"CQ <PJ4/K1ABC>", Message{Step: CQ, DeCall: "PJ4/K1ABC", DeHashed: true}},
+		{"W1AW K0SWE", Message{Step: Call, DxCall: "W1AW", DeCall: "K0SWE"}},
+		{"W1AW K0SWE DM79", Message{Step: Grid, DxCall: "W1AW", DeCall: "K0SWE", Grid: "DM79"}},
+		{"W1AW K0SWE -12", Message{Step: Report, DxCall: "W1AW", DeCall: "K0SWE", Report: -12}},
+		{"W1AW K0SWE +05", Message{Step: Report, DxCall: "W1AW", DeCall: "K0SWE", Report: 5}},
+		{"K0SWE W1AW R-09", Message{Step: RogerReport, DxCall: "K0SWE", DeCall: "W1AW", Report: -9}},
+		{"W1AW K0SWE RRR", Message{Step: RRR, DxCall: "W1AW", DeCall: "K0SWE"}},
+		{"K0SWE W1AW RR73", Message{Step: RR73, DxCall: "K0SWE", DeCall: "W1AW"}},
+		{"W1AW K0SWE 73", Message{Step: SeventyThree, DxCall: "W1AW", DeCall: "K0SWE"}},
+		{"<W1AW> K0SWE/P -03",
+			Message{Step: Report, DxCall: "W1AW", DxHashed: true, DeCall: "K0SWE/P", Report: -3}},
+		{"<...> K0SWE RR73", Message{Step: RR73, DxHashed: true, DeCall: "K0SWE"}},
+		{"TNX BOB 73 GL", Message{Step: FreeText}},
+		{"73 GL", Message{Step: FreeText}},
+		{"CQ POTA", Message{Step: FreeText}},
+		{"W1AW K0SWE XYZ", Message{Step: FreeText}},
+		{"W1AW K0SWE/XYZZY -12",
+			Message{Step: Report, DxCall: "W1AW", DeCall: "K0SWE/XYZZY", Report: -12}},
+		{"CQ VK100ANZAC QF56", Message{Step: CQ, DeCall: "VK100ANZAC", Grid: "QF56"}},
+		{"", Message{Step: FreeText}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			tt.want.Text = tt.text
+			if got := Parse(tt.text); got != tt.want {
+				t.Errorf("Parse(%q)\n got  %+v\n want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallParts(t *testing.T) {
+	m := Parse("VK100ANZAC VE3/K0SWE/P R-05")
+	if m.Step != RogerReport {
+		t.Fatalf("got %+v", m)
+	}
+	de, err := m.DeCallParts()
+	if err != nil || de != (callsign.Call{Prefix: "VE3", Base: "K0SWE", Suffix: "P"}) {
+		t.Errorf("got %+v, %v", de, err)
+	}
+	if _, err := m.DxCallParts(); !errors.Is(err, callsign.InvalidError) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestStepString(t *testing.T) {
+	if got := SeventyThree.String(); got != "73" {
+		t.Errorf("got %s", got)
+	}
+	if got := Step(42).String(); got != "Step(42)" {
+		t.Errorf("got %s", got)
+	}
+}
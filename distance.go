@@ -0,0 +1,21 @@
+package wsjtx
+
+import "github.com/k0swe/wsjtx-go/v4/grid"
+
+// DistanceToDx returns the great circle distance in kilometers from DeGrid to DxGrid, along the
+// short path. It fails with a grid.InvalidError if either grid is empty or isn't a locator.
+func (m StatusMessage) DistanceToDx() (float64, error) {
+	return grid.Distance(m.DeGrid, m.DxGrid)
+}
+
+// BearingToDx returns the bearings in degrees from DeGrid to DxGrid, along the short and long
+// paths.
+func (m StatusMessage) BearingToDx() (short, long float64, err error) {
+	return grid.Bearing(m.DeGrid, m.DxGrid)
+}
+
+// DistanceToDx returns the great circle distance in kilometers from MyGrid to DxGrid, along the
+// short path. It fails with a grid.InvalidError if either grid is empty or isn't a locator.
+func (m QsoLoggedMessage) DistanceToDx() (float64, error) {
+	return grid.Distance(m.MyGrid, m.DxGrid)
+}
@@ -0,0 +1,28 @@
+package wsjtx
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/k0swe/wsjtx-go/v4/grid"
+)
+
+func TestDistanceToDx(t *testing.T) {
+	status := StatusMessage{DeGrid: "DM79", DxGrid: "FN31"}
+	distance, err := status.DistanceToDx()
+	if err != nil || math.Abs(distance-2699.36) > 0.01 {
+		t.Errorf("got %f, %v", distance, err)
+	}
+	short, long, err := status.BearingToDx()
+	if err != nil || math.Abs(short-74.87) > 0.01 || math.Abs(long-254.87) > 0.01 {
+		t.Errorf("got %f, %f, %v", short, long, err)
+	}
+	logged := QsoLoggedMessage{MyGrid: "DM79jx", DxGrid: "DM79jx"}
+	if distance, err := logged.DistanceToDx(); err != nil || distance != 0 {
+		t.Errorf("got %f, %v", distance, err)
+	}
+	if _, err := (StatusMessage{DeGrid: "DM79"}).DistanceToDx(); !errors.Is(err, grid.InvalidError) {
+		t.Errorf("got %v", err)
+	}
+}
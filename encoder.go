@@ -1,159 +1,369 @@
 package wsjtx
 
 import (
-	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"math"
+	"unicode/utf8"
 
+	"github.com/k0swe/wsjtx-go/v4/callsign"
 	"github.com/mazznoer/csscolorparser"
 )
 
-func encodeHeartbeat(msg HeartbeatMessage) ([]byte, error) {
-	e := newEncoder()
-	e.encodeUint32(heartbeatNum)
-	e.encodeUtf8(msg.Id)
+// EncodeError is wrapped by every error about a message which can't be encoded. Errors about a
+// particular field are a *FieldError, which says which one.
+var EncodeError = errors.New("encode error")
+var InvalidUtf8Error = fmt.Errorf("%w: string isn't valid UTF-8", EncodeError)
+var StringTooLongError = fmt.Errorf("%w: string is too long", EncodeError)
+var MessageTooLongError = fmt.Errorf("%w: message is too long", EncodeError)
+var InvalidColorError = fmt.Errorf("%w: invalid color", EncodeError)
+var OutOfRangeError = fmt.Errorf("%w: value out of range", EncodeError)
+var InvalidCallsignError = fmt.Errorf("%w: invalid callsign", EncodeError)
+
+// Encode serializes any message as WSJT-X would put it on the wire, with the default schema number
+// in its header. Together with Parse, this allows impersonating WSJT-X as well as talking to it.
+func Encode(msg Message) ([]byte, error) {
+	return EncodeSchema(msg, defaultSchema)
+}
+
+// EncodeSchema is like Encode, but puts the given schema number in the header, e.g. one which was
+// negotiated with the other end.
+func EncodeSchema(msg Message, schema uint32) ([]byte, error) {
+	b, err := AppendSchema(make([]byte, 0, encodeCap), msg, schema)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// encodeCap is enough room for most messages, so that encoding only allocates once.
+const encodeCap = 256
+
+// Append is like Encode, but appends the encoded message to dst and returns the extended slice.
+// Reusing a buffer this way avoids allocating one for every message. On error, dst is returned
+// unchanged.
+func Append(dst []byte, msg Message) ([]byte, error) {
+	return encode(dst, msg, defaultSchema)
+}
+
+// AppendSchema is like Append, but puts the given schema number in the header.
+func AppendSchema(dst []byte, msg Message, schema uint32) ([]byte, error) {
+	if schema < minSchema || schema > maxSchema {
+		return dst, fmt.Errorf("%w: can't encode with schema %d, only %d through %d",
+			EncodeError, schema, minSchema, maxSchema)
+	}
+	return encode(dst, msg, schema)
+}
+
+// encode appends the message to dst with the given schema number in its header, returning dst
+// unchanged on error.
+func encode(dst []byte, msg Message, schema uint32) ([]byte, error) {
+	b, err := encodeMessage(dst, msg, schema)
+	if err != nil {
+		return dst, err
+	}
+	return b, nil
+}
+
+func encodeMessage(dst []byte, msg Message, schema uint32) ([]byte, error) {
+	switch m := msg.(type) {
+	case HeartbeatMessage:
+		return encodeHeartbeat(dst, m, schema)
+	case StatusMessage:
+		return encodeStatus(dst, m, schema)
+	case DecodeMessage:
+		return encodeDecode(dst, m, schema)
+	case ClearMessage:
+		return encodeClear(dst, m, schema)
+	case ReplyMessage:
+		return encodeReply(dst, m, schema)
+	case QsoLoggedMessage:
+		return encodeQsoLogged(dst, m, schema)
+	case CloseMessage:
+		return encodeClose(dst, m, schema)
+	case ReplayMessage:
+		return encodeReplay(dst, m, schema)
+	case HaltTxMessage:
+		return encodeHaltTx(dst, m, schema)
+	case FreeTextMessage:
+		return encodeFreeText(dst, m, schema)
+	case WSPRDecodeMessage:
+		return encodeWsprDecode(dst, m, schema)
+	case LocationMessage:
+		return encodeLocation(dst, m, schema)
+	case LoggedAdifMessage:
+		return encodeLoggedAdif(dst, m, schema)
+	case HighlightCallsignMessage:
+		return encodeHighlightCallsign(dst, m, schema)
+	case SwitchConfigurationMessage:
+		return encodeSwitchConfiguration(dst, m, schema)
+	case ConfigureMessage:
+		return encodeConfigure(dst, m, schema)
+	case AnnotationInfoMessage:
+		return encodeAnnotationInfo(dst, m, schema)
+	case UnknownMessage:
+		return encodeUnknown(dst, m, schema)
+	}
+	if msg == nil {
+		return dst, fmt.Errorf("%w: can't encode a nil message", EncodeError)
+	}
+	return dst, fmt.Errorf("%w: can't encode %s messages", EncodeError, msg.Type())
+}
+
+func encodeHeartbeat(dst []byte, msg HeartbeatMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, HeartbeatType)
+	e.encodeUtf8("Id", msg.Id)
 	e.encodeUint32(msg.MaxSchema)
-	e.encodeUtf8(msg.Version)
-	e.encodeUtf8(msg.Revision)
+	e.encodeUtf8("Version", msg.Version)
+	e.encodeUtf8("Revision", msg.Revision)
+	return e.finish()
+}
+
+func encodeStatus(dst []byte, msg StatusMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, StatusType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeUint64(msg.DialFrequency)
+	e.encodeUtf8("Mode", msg.Mode)
+	e.encodeUtf8("DxCall", msg.DxCall)
+	e.encodeUtf8("Report", msg.Report)
+	e.encodeUtf8("TxMode", msg.TxMode)
+	e.encodeBool(msg.TxEnabled)
+	e.encodeBool(msg.Transmitting)
+	e.encodeBool(msg.Decoding)
+	e.encodeUint32(msg.RxDF)
+	e.encodeUint32(msg.TxDF)
+	e.encodeUtf8("DeCall", msg.DeCall)
+	e.encodeUtf8("DeGrid", msg.DeGrid)
+	e.encodeUtf8("DxGrid", msg.DxGrid)
+	e.encodeBool(msg.TxWatchdog)
+	e.encodeUtf8("SubMode", msg.SubMode)
+	e.encodeBool(msg.FastMode)
+	e.encodeEnum("SpecialOperationMode", uint8(msg.SpecialOperationMode),
+		uint8(SpecialOperationARRLDigi))
+	e.encodeUint32(msg.FrequencyTolerance)
+	e.encodeUint32(msg.TRPeriod)
+	e.encodeUtf8("ConfigurationName", msg.ConfigurationName)
+	e.encodeUtf8("TxMessage", msg.TxMessage)
 	return e.finish()
 }
 
-func encodeClear(msg ClearMessage) ([]byte, error) {
-	e := newEncoder()
-	e.encodeUint32(clearNum)
-	e.encodeUtf8(msg.Id)
-	e.encodeUint8(msg.Window)
+func encodeDecode(dst []byte, msg DecodeMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, DecodeType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeBool(msg.New)
+	e.encodeUint32(msg.Time)
+	e.encodeInt32(msg.Snr)
+	e.encodeFloat64(msg.DeltaTimeSec)
+	e.encodeUint32(msg.DeltaFrequencyHz)
+	e.encodeUtf8("Mode", msg.Mode)
+	e.encodeUtf8("Message", msg.Message)
+	e.encodeBool(msg.LowConfidence)
+	e.encodeBool(msg.OffAir)
 	return e.finish()
 }
 
-func encodeReply(msg ReplyMessage) ([]byte, error) {
-	e := newEncoder()
-	e.encodeUint32(replyNum)
-	e.encodeUtf8(msg.Id)
+func encodeClear(dst []byte, msg ClearMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, ClearType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeEnum("Window", msg.Window, maxClearWindow)
+	return e.finish()
+}
+
+func encodeReply(dst []byte, msg ReplyMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, ReplyType)
+	e.encodeUtf8("Id", msg.Id)
 	e.encodeUint32(msg.Time)
 	e.encodeInt32(msg.Snr)
 	e.encodeFloat64(msg.DeltaTimeSec)
 	e.encodeUint32(msg.DeltaFrequencyHz)
-	e.encodeUtf8(msg.Mode)
-	e.encodeUtf8(msg.Message)
+	e.encodeUtf8("Mode", msg.Mode)
+	e.encodeUtf8("Message", msg.Message)
 	e.encodeBool(msg.LowConfidence)
-	e.encodeUint8(msg.Modifiers)
+	e.encodeModifiers("Modifiers", msg.Modifiers)
 	return e.finish()
 }
 
-func encodeClose(msg CloseMessage) ([]byte, error) {
-	e := newEncoder()
-	e.encodeUint32(closeNum)
-	e.encodeUtf8(msg.Id)
+func encodeQsoLogged(dst []byte, msg QsoLoggedMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, QsoLoggedType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeQDateTime("DateTimeOff", msg.DateTimeOff)
+	e.encodeUtf8("DxCall", msg.DxCall)
+	e.encodeUtf8("DxGrid", msg.DxGrid)
+	e.encodeUint64(msg.TxFrequency)
+	e.encodeUtf8("Mode", msg.Mode)
+	e.encodeUtf8("ReportSent", msg.ReportSent)
+	e.encodeUtf8("ReportReceived", msg.ReportReceived)
+	e.encodeUtf8("TxPower", msg.TxPower)
+	e.encodeUtf8("Comments", msg.Comments)
+	e.encodeUtf8("Name", msg.Name)
+	e.encodeQDateTime("DateTimeOn", msg.DateTimeOn)
+	e.encodeUtf8("OperatorCall", msg.OperatorCall)
+	e.encodeUtf8("MyCall", msg.MyCall)
+	e.encodeUtf8("MyGrid", msg.MyGrid)
+	e.encodeUtf8("ExchangeSent", msg.ExchangeSent)
+	e.encodeUtf8("ExchangeReceived", msg.ExchangeReceived)
+	e.encodeUtf8("ADIFPropagationMode", msg.ADIFPropagationMode)
 	return e.finish()
 }
 
-func encodeReplay(msg ReplayMessage) ([]byte, error) {
-	e := newEncoder()
-	e.encodeUint32(replayNum)
-	e.encodeUtf8(msg.Id)
+func encodeClose(dst []byte, msg CloseMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, CloseType)
+	e.encodeUtf8("Id", msg.Id)
 	return e.finish()
 }
 
-func encodeHaltTx(msg HaltTxMessage) ([]byte, error) {
-	e := newEncoder()
-	e.encodeUint32(haltTxNum)
-	e.encodeUtf8(msg.Id)
+func encodeReplay(dst []byte, msg ReplayMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, ReplayType)
+	e.encodeUtf8("Id", msg.Id)
+	return e.finish()
+}
+
+func encodeHaltTx(dst []byte, msg HaltTxMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, HaltTxType)
+	e.encodeUtf8("Id", msg.Id)
 	e.encodeBool(msg.AutoTxOnly)
 	return e.finish()
 }
 
-func encodeFreeText(msg FreeTextMessage) ([]byte, error) {
-	e := newEncoder()
-	e.encodeUint32(freeTextNum)
-	e.encodeUtf8(msg.Id)
-	e.encodeUtf8(msg.Text)
+func encodeFreeText(dst []byte, msg FreeTextMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, FreeTextType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeUtf8("Text", msg.Text)
 	e.encodeBool(msg.Send)
 	return e.finish()
 }
 
-func encodeLocation(msg LocationMessage) ([]byte, error) {
-	e := newEncoder()
-	e.encodeUint32(locationNum)
-	e.encodeUtf8(msg.Id)
-	e.encodeUtf8(msg.Location)
+func encodeWsprDecode(dst []byte, msg WSPRDecodeMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, WSPRDecodeType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeBool(msg.New)
+	e.encodeUint32(msg.Time)
+	e.encodeInt32(msg.Snr)
+	e.encodeFloat64(msg.DeltaTime)
+	e.encodeUint64(msg.Frequency)
+	e.encodeInt32(msg.Drift)
+	e.encodeUtf8("Callsign", msg.Callsign)
+	e.encodeUtf8("Grid", msg.Grid)
+	e.encodeInt32(msg.Power)
+	e.encodeBool(msg.OffAir)
 	return e.finish()
 }
 
-func encodeHighlightCallsign(msg HighlightCallsignMessage) ([]byte, error) {
-	e := newEncoder()
-	e.encodeUint32(highlightCallsignNum)
-	e.encodeUtf8(msg.Id)
-	e.encodeUtf8(msg.Callsign)
-	if err := e.encodeColor(msg.BackgroundColor, msg.Reset); err != nil {
-		return []byte{}, err
-	}
-	if err := e.encodeColor(msg.ForegroundColor, msg.Reset); err != nil {
-		return []byte{}, err
-	}
+func encodeLocation(dst []byte, msg LocationMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, LocationType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeUtf8("Location", msg.Location)
+	return e.finish()
+}
+
+func encodeLoggedAdif(dst []byte, msg LoggedAdifMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, LoggedAdifType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeUtf8("Adif", msg.Adif)
+	return e.finish()
+}
+
+func encodeHighlightCallsign(dst []byte, msg HighlightCallsignMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, HighlightCallsignType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeUtf8("Callsign", msg.Callsign)
+	e.encodeColor("BackgroundColor", msg.BackgroundColor, msg.Reset)
+	e.encodeColor("ForegroundColor", msg.ForegroundColor, msg.Reset)
 	e.encodeBool(msg.HighlightLast)
 	return e.finish()
 }
 
-func encodeSwitchConfiguration(msg SwitchConfigurationMessage) ([]byte, error) {
-	e := newEncoder()
-	e.encodeUint32(switchConfigurationNum)
-	e.encodeUtf8(msg.Id)
-	e.encodeUtf8(msg.ConfigurationName)
+func encodeSwitchConfiguration(dst []byte, msg SwitchConfigurationMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, SwitchConfigurationType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeUtf8("ConfigurationName", msg.ConfigurationName)
 	return e.finish()
 }
 
-func encodeConfigure(msg ConfigureMessage) ([]byte, error) {
-	e := newEncoder()
-	e.encodeUint32(configureNum)
-	e.encodeUtf8(msg.Id)
-	e.encodeUtf8(msg.Mode)
+func encodeConfigure(dst []byte, msg ConfigureMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, ConfigureType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeUtf8("Mode", msg.Mode)
 	e.encodeUint32(msg.FrequencyTolerance)
-	e.encodeUtf8(msg.Submode)
+	e.encodeUtf8("Submode", msg.Submode)
 	e.encodeBool(msg.FastMode)
 	e.encodeUint32(msg.TRPeriod)
 	e.encodeUint32(msg.RxDF)
-	e.encodeUtf8(msg.DXCall)
-	e.encodeUtf8(msg.DXGrid)
+	e.encodeCallsign("DXCall", msg.DXCall)
+	e.encodeUtf8("DXGrid", msg.DXGrid)
 	e.encodeBool(msg.GenerateMessages)
 	return e.finish()
 }
 
+func encodeAnnotationInfo(dst []byte, msg AnnotationInfoMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, AnnotationInfoType)
+	e.encodeUtf8("Id", msg.Id)
+	e.encodeUtf8("DxCall", msg.DxCall)
+	e.encodeBool(msg.SortOrderProvided)
+	e.encodeUint32(msg.SortOrder)
+	return e.finish()
+}
+
+// encodeUnknown passes on an unknown message's payload unchanged.
+func encodeUnknown(dst []byte, msg UnknownMessage, schema uint32) ([]byte, error) {
+	e := newEncoder(dst, schema, msg.TypeNumber)
+	e.buf = append(e.buf, msg.Payload...)
+	return e.finish()
+}
+
+// The largest Clear Window WSJT-X understands, and the keyboard modifiers which a Reply may carry:
+// Shift, Ctrl, Alt, Meta, Keypad and Group switch.
+const maxClearWindow = 2
+const replyModifiersMask = 0x7e
+
+// encoder appends a message to a buffer, which only grows if it doesn't have room. Like the
+// parser, it keeps the first invalid field it meets in err, and finish reports it.
 type encoder struct {
-	buf *bytes.Buffer
+	buf         []byte
+	start       int
+	schema      uint32
+	messageType MessageType
+	err         error
 }
 
-func newEncoder() encoder {
-	e := encoder{bytes.NewBuffer(make([]byte, bufLen))}
-	e.buf.Reset()
+func newEncoder(dst []byte, schema uint32, messageType MessageType) encoder {
+	e := encoder{buf: dst, start: len(dst), schema: schema, messageType: messageType}
 	e.encodeUint32(magic)
 	e.encodeUint32(schema)
+	e.encodeUint32(uint32(messageType))
 	return e
 }
 
-func (e encoder) encodeUint8(num uint8) {
-	e.buf.WriteByte(num)
+// fail records the first invalid field, along with where it would have started in the datagram.
+func (e *encoder) fail(field string, err error) {
+	if e.err == nil {
+		e.err = &FieldError{
+			Message: e.messageType.String(),
+			Field:   field,
+			Offset:  len(e.buf) - e.start,
+			Err:     err,
+		}
+	}
 }
 
-func (e encoder) encodeUint16(num uint16) {
-	bin := make([]byte, 2)
-	binary.BigEndian.PutUint16(bin, num)
-	e.buf.Write(bin)
+func (e *encoder) encodeUint8(num uint8) {
+	e.buf = append(e.buf, num)
 }
 
-func (e encoder) encodeUint32(num uint32) {
-	bin := make([]byte, 4)
-	binary.BigEndian.PutUint32(bin, num)
-	e.buf.Write(bin)
+func (e *encoder) encodeUint16(num uint16) {
+	e.buf = binary.BigEndian.AppendUint16(e.buf, num)
 }
 
-func (e encoder) encodeUint64(num uint64) {
-	bin := make([]byte, 8)
-	binary.BigEndian.PutUint64(bin, num)
-	e.buf.Write(bin)
+func (e *encoder) encodeUint32(num uint32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, num)
 }
 
-func (e encoder) encodeBool(b bool) {
+func (e *encoder) encodeUint64(num uint64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, num)
+}
+
+func (e *encoder) encodeBool(b bool) {
 	if b {
 		e.encodeUint8(1)
 	} else {
@@ -162,25 +372,32 @@ func (e encoder) encodeBool(b bool) {
 
 }
 
-func (e encoder) encodeInt32(num int32) {
+func (e *encoder) encodeInt32(num int32) {
 	e.encodeUint32(uint32(num))
 }
 
-func (e encoder) encodeFloat64(num float64) {
+func (e *encoder) encodeFloat64(num float64) {
 	e.encodeUint64(math.Float64bits(num))
 }
 
-func (e encoder) encodeUtf8(str string) {
+func (e *encoder) encodeUtf8(field string, str string) {
+	if len(str) > MaxDatagramSize {
+		e.fail(field, fmt.Errorf("%w: %d bytes", StringTooLongError, len(str)))
+		return
+	}
+	if !utf8.ValidString(str) {
+		e.fail(field, InvalidUtf8Error)
+	}
 	strlen := uint32(len(str))
 	if strlen == 0 {
 		e.encodeUint32(qDataStreamNull)
 		return
 	}
 	e.encodeUint32(strlen)
-	e.buf.WriteString(str)
+	e.buf = append(e.buf, str...)
 }
 
-func (e encoder) encodeColor(color string, invalid bool) error {
+func (e *encoder) encodeColor(field string, color string, invalid bool) {
 	// Spec enum: https://github.com/radekp/qt/blob/b881d8fb/src/gui/painting/qcolor.h#L70
 	const invalidSpec = uint8(0)
 	const rgbSpec = uint8(1)
@@ -194,7 +411,7 @@ func (e encoder) encodeColor(color string, invalid bool) error {
 	// pre-multiplied to range 0x0 to 0xffff
 	c, err := csscolorparser.Parse(color)
 	if err != nil {
-		return err
+		e.fail(field, fmt.Errorf("%w: %v", InvalidColorError, err))
 	}
 	r, g, b, a := c.RGBA()
 
@@ -205,11 +422,41 @@ func (e encoder) encodeColor(color string, invalid bool) error {
 	e.encodeUint16(uint16(g))
 	e.encodeUint16(uint16(b))
 	e.encodeUint16(pad)
-	return nil
 }
 
-func (e encoder) finish() ([]byte, error) {
-	ret := make([]byte, e.buf.Len())
-	_, err := e.buf.Read(ret)
-	return ret, err
+// encodeEnum writes an enumerated field, which must be no more than max.
+func (e *encoder) encodeEnum(field string, value uint8, max uint8) {
+	if value > max {
+		e.fail(field, fmt.Errorf("%w: %d is more than %d", OutOfRangeError, value, max))
+	}
+	e.encodeUint8(value)
+}
+
+// encodeCallsign writes a callsign, which must be well formed if given. An empty one is allowed,
+// e.g. a Configure's DXCall, which WSJT-X leaves as it is when empty.
+func (e *encoder) encodeCallsign(field string, call string) {
+	if call != "" && !callsign.Valid(call) {
+		e.fail(field, fmt.Errorf("%w: %q", InvalidCallsignError, call))
+	}
+	e.encodeUtf8(field, call)
+}
+
+// encodeModifiers writes a Reply's keyboard modifiers, which must only be those Qt defines.
+func (e *encoder) encodeModifiers(field string, modifiers uint8) {
+	if modifiers&^replyModifiersMask != 0 {
+		e.fail(field, fmt.Errorf("%w: modifiers %#02x aren't within %#02x",
+			OutOfRangeError, modifiers, replyModifiersMask))
+	}
+	e.encodeUint8(modifiers)
+}
+
+func (e *encoder) finish() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	if size := len(e.buf) - e.start; size > MaxDatagramSize {
+		return nil, fmt.Errorf("%w: %s message is %d bytes, more than fits in a datagram",
+			MessageTooLongError, e.messageType, size)
+	}
+	return e.buf, nil
 }
@@ -2,8 +2,11 @@ package wsjtx
 
 import (
 	"encoding/hex"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_encodeHeartbeat(t *testing.T) {
@@ -29,7 +32,7 @@ func Test_encodeHeartbeat(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := encodeHeartbeat(tt.args.msg)
+			got, err := encodeHeartbeat(nil, tt.args.msg, defaultSchema)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("encodeHeartbeat() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -42,6 +45,91 @@ func Test_encodeHeartbeat(t *testing.T) {
 	}
 }
 
+func Test_encodeStatus(t *testing.T) {
+	type args struct {
+		msg StatusMessage
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "encodeStatus",
+			args: args{msg: StatusMessage{
+				Id:                 "WSJT-X",
+				DialFrequency:      7074000,
+				Mode:               "FT8",
+				Report:             "-15",
+				TxMode:             "FT8",
+				RxDF:               883,
+				TxDF:               1950,
+				DeCall:             "K0SWE",
+				DeGrid:             "DM79LV",
+				FrequencyTolerance: 4294967295,
+				TRPeriod:           4294967295,
+				ConfigurationName:  "Default",
+				TxMessage:          "CQ K0SWE DM79"}},
+			want:    decodeHex("adbccbda00000002000000010000000657534a542d5800000000006bf0d000000003465438ffffffff000000032d313500000003465438000000000003730000079e000000054b3053574500000006444d37394c56ffffffff00ffffffff0000ffffffffffffffff0000000744656661756c740000000d4351204b3053574520444d3739"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeStatus(nil, tt.args.msg, defaultSchema)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("encodeStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeStatus() got = %v, want %v",
+					hex.EncodeToString(got), hex.EncodeToString(tt.want))
+			}
+		})
+	}
+}
+
+func Test_encodeDecode(t *testing.T) {
+	type args struct {
+		msg DecodeMessage
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "encodeDecode",
+			args: args{msg: DecodeMessage{
+				Id:               "WSJT-X",
+				New:              true,
+				Time:             39435000,
+				Snr:              -5,
+				DeltaTimeSec:     0.20000000298023224,
+				DeltaFrequencyHz: 1302,
+				Mode:             "~",
+				Message:          "JA2EJP N4BP 73"}},
+			want:    decodeHex("adbccbda00000002000000020000000657534a542d58010259baf8fffffffb3fc99999a000000000000516000000017e0000000e4a4132454a50204e3442502037330000"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeDecode(nil, tt.args.msg, defaultSchema)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("encodeDecode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeDecode() got = %v, want %v",
+					hex.EncodeToString(got), hex.EncodeToString(tt.want))
+			}
+		})
+	}
+}
+
 func Test_encodeClear(t *testing.T) {
 	type args struct {
 		msg ClearMessage
@@ -61,7 +149,7 @@ func Test_encodeClear(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := encodeClear(tt.args.msg)
+			got, err := encodeClear(nil, tt.args.msg, defaultSchema)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("encodeClear() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -103,7 +191,7 @@ func Test_encodeReply(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := encodeReply(tt.args.msg)
+			got, err := encodeReply(nil, tt.args.msg, defaultSchema)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("encodeReply() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -116,6 +204,56 @@ func Test_encodeReply(t *testing.T) {
 	}
 }
 
+func Test_encodeQsoLogged(t *testing.T) {
+	type args struct {
+		msg QsoLoggedMessage
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "encodeQsoLogged",
+			args: args{msg: QsoLoggedMessage{
+				Id:                  "WSJT-X",
+				DateTimeOff:         time.Date(2020, 10, 30, 11, 29, 57, 320000000, time.UTC),
+				DxCall:              "T3ST",
+				DxGrid:              "JK73",
+				TxFrequency:         7075950,
+				Mode:                "FT8",
+				ReportSent:          "-3",
+				ReportReceived:      "-7",
+				TxPower:             "5",
+				Comments:            "Comment",
+				Name:                "Joe",
+				DateTimeOn:          time.Date(2020, 10, 30, 11, 28, 57, 320000000, time.UTC),
+				OperatorCall:        "T3STR",
+				MyCall:              "K0SWE",
+				MyGrid:              "DM79LV",
+				ExchangeSent:        "1B",
+				ExchangeReceived:    "1D",
+				ADIFPropagationMode: "ION"}},
+			want:    decodeHex("adbccbda00000002000000050000000657534a542d5800000000002586110277ac48010000000454335354000000044a4b373300000000006bf86e00000003465438000000022d33000000022d37000000013500000007436f6d6d656e74000000034a6f6500000000002586110276c1e801000000055433535452000000054b3053574500000006444d37394c5600000002314200000002314400000003494f4e"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeQsoLogged(nil, tt.args.msg, defaultSchema)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("encodeQsoLogged() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeQsoLogged() got = %v, want %v",
+					hex.EncodeToString(got), hex.EncodeToString(tt.want))
+			}
+		})
+	}
+}
+
 func Test_encodeClose(t *testing.T) {
 	type args struct {
 		msg CloseMessage
@@ -135,7 +273,7 @@ func Test_encodeClose(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := encodeClose(tt.args.msg)
+			got, err := encodeClose(nil, tt.args.msg, defaultSchema)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("encodeClose() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -167,7 +305,7 @@ func Test_encodeReplay(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := encodeReplay(tt.args.msg)
+			got, err := encodeReplay(nil, tt.args.msg, defaultSchema)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("encodeReplay() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -199,7 +337,7 @@ func Test_encodeHaltTx(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := encodeHaltTx(tt.args.msg)
+			got, err := encodeHaltTx(nil, tt.args.msg, defaultSchema)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("encodeHaltTx() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -231,7 +369,7 @@ func Test_encodeFreeText(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := encodeFreeText(tt.args.msg)
+			got, err := encodeFreeText(nil, tt.args.msg, defaultSchema)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("encodeFreeText() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -244,6 +382,47 @@ func Test_encodeFreeText(t *testing.T) {
 	}
 }
 
+func Test_encodeWsprDecode(t *testing.T) {
+	type args struct {
+		msg WSPRDecodeMessage
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "encodeWsprDecode",
+			args: args{msg: WSPRDecodeMessage{
+				Id:        "WSJT-X",
+				New:       true,
+				Time:      45480000,
+				Snr:       -18,
+				DeltaTime: -0.5,
+				Frequency: 7040115,
+				Callsign:  "K6TGW",
+				Grid:      "CM95",
+				Power:     23}},
+			want:    decodeHex("adbccbda000000020000000a0000000657534a542d580102b5f840ffffffeebfe000000000000000000000006b6c7300000000000000054b3654475700000004434d39350000001700"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeWsprDecode(nil, tt.args.msg, defaultSchema)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("encodeWsprDecode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeWsprDecode() got = %v, want %v",
+					hex.EncodeToString(got), hex.EncodeToString(tt.want))
+			}
+		})
+	}
+}
+
 func Test_encodeLocation(t *testing.T) {
 	type args struct {
 		msg LocationMessage
@@ -263,7 +442,7 @@ func Test_encodeLocation(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := encodeLocation(tt.args.msg)
+			got, err := encodeLocation(nil, tt.args.msg, defaultSchema)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("encodeLocation() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -276,6 +455,40 @@ func Test_encodeLocation(t *testing.T) {
 	}
 }
 
+func Test_encodeLoggedAdif(t *testing.T) {
+	type args struct {
+		msg LoggedAdifMessage
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "encodeLoggedAdif",
+			args: args{msg: LoggedAdifMessage{
+				Id:   "WSJT-X",
+				Adif: "<call:4>T3ST <gridsquare:4>JK73 <mode:3>FT8 <EOR>"}},
+			want:    decodeHex("adbccbda000000020000000c0000000657534a542d58000000313c63616c6c3a343e54335354203c677269647371756172653a343e4a4b3733203c6d6f64653a333e465438203c454f523e"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeLoggedAdif(nil, tt.args.msg, defaultSchema)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("encodeLoggedAdif() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeLoggedAdif() got = %v, want %v",
+					hex.EncodeToString(got), hex.EncodeToString(tt.want))
+			}
+		})
+	}
+}
+
 func Test_encodeHighlightCallsign(t *testing.T) {
 	type args struct {
 		msg HighlightCallsignMessage
@@ -315,7 +528,7 @@ func Test_encodeHighlightCallsign(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := encodeHighlightCallsign(tt.args.msg)
+			got, err := encodeHighlightCallsign(nil, tt.args.msg, defaultSchema)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("encodeHighlightCallsign() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -347,7 +560,7 @@ func Test_encodeSwitchConfiguration(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := encodeSwitchConfiguration(tt.args.msg)
+			got, err := encodeSwitchConfiguration(nil, tt.args.msg, defaultSchema)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("encodeSwitchConfiguration() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -390,7 +603,7 @@ func Test_encodeConfigure(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := encodeConfigure(tt.args.msg)
+			got, err := encodeConfigure(nil, tt.args.msg, defaultSchema)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("encodeConfigure() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -403,7 +616,212 @@ func Test_encodeConfigure(t *testing.T) {
 	}
 }
 
+func Test_encodeAnnotationInfo(t *testing.T) {
+	type args struct {
+		msg AnnotationInfoMessage
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "encodeAnnotationInfo",
+			args: args{msg: AnnotationInfoMessage{
+				Id:                "WSJT-X",
+				DxCall:            "K1ABC",
+				SortOrderProvided: true,
+				SortOrder:         5,
+			}},
+			want:    decodeHex("adbccbda00000002000000100000000657534a542d58000000054b314142430100000005"),
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeAnnotationInfo(nil, tt.args.msg, defaultSchema)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("encodeAnnotationInfo() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeAnnotationInfo() got = %v, want %v",
+					hex.EncodeToString(got), hex.EncodeToString(tt.want))
+			}
+		})
+	}
+}
+
 func decodeHex(str string) []byte {
 	bits, _ := hex.DecodeString(str)
 	return bits
 }
+
+func TestEncodeSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     Message
+		schema  uint32
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:   "schema 2",
+			msg:    ReplayMessage{Id: "WSJT-X"},
+			schema: 2,
+			want:   decodeHex("adbccbda00000002000000070000000657534a542d58"),
+		},
+		{
+			name:   "schema 3",
+			msg:    ReplayMessage{Id: "WSJT-X"},
+			schema: 3,
+			want:   decodeHex("adbccbda00000003000000070000000657534a542d58"),
+		},
+		{
+			name: "unknown message",
+			msg: UnknownMessage{
+				Id:         "WSJT-X",
+				TypeNumber: 99,
+				Payload:    decodeHex("0000000657534a542d58cafe"),
+			},
+			schema: 2,
+			want:   decodeHex("adbccbda00000002000000630000000657534a542d58cafe"),
+		},
+		{
+			name:   "out message",
+			msg:    CloseMessage{Id: "WSJT-X"},
+			schema: 3,
+			want:   decodeHex("adbccbda00000003000000060000000657534a542d58"),
+		},
+		{
+			name:    "schema too new",
+			msg:     ReplayMessage{Id: "WSJT-X"},
+			schema:  4,
+			wantErr: true,
+		},
+		{
+			name:    "nil message",
+			msg:     nil,
+			schema:  2,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EncodeSchema(tt.msg, tt.schema)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EncodeSchema() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EncodeSchema() got = %v, want %v",
+					hex.EncodeToString(got), hex.EncodeToString(tt.want))
+			}
+		})
+	}
+}
+
+func TestAppend(t *testing.T) {
+	prefix := []byte("prefix")
+	got, err := Append(prefix, ReplayMessage{Id: "WSJT-X"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte("prefix"), decodeHex("adbccbda00000002000000070000000657534a542d58")...)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", hex.EncodeToString(got), hex.EncodeToString(want))
+	}
+
+	got, err = Append(prefix, HighlightCallsignMessage{Id: "WSJT-X", BackgroundColor: "nope"})
+	if err == nil {
+		t.Error("expected an error")
+	}
+	if string(got) != "prefix" {
+		t.Errorf("got %q after an error, want the prefix unchanged", got)
+	}
+
+	buf := make([]byte, 0, 1024)
+	var status Message = StatusMessage{Id: "WSJT-X", DialFrequency: 14074000, Mode: "FT8"}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, _ = Append(buf[:0], status)
+	})
+	if allocs > 0 {
+		t.Errorf("appending to a big enough buffer allocated %v times", allocs)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	status := StatusMessage{Id: "WSJT-X", DialFrequency: 14074000, Mode: "FT8", DxCall: "K1ABC",
+		Report: "-15", TxMode: "FT8", DeCall: "K0SWE", DeGrid: "DM79", ConfigurationName: "Default"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = Encode(status)
+	}
+}
+
+func BenchmarkAppend(b *testing.B) {
+	status := StatusMessage{Id: "WSJT-X", DialFrequency: 14074000, Mode: "FT8", DxCall: "K1ABC",
+		Report: "-15", TxMode: "FT8", DeCall: "K0SWE", DeGrid: "DM79", ConfigurationName: "Default"}
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = Append(buf[:0], status)
+	}
+}
+
+func TestEncodeValidation(t *testing.T) {
+	long := strings.Repeat("x", MaxDatagramSize/2)
+	tests := []struct {
+		name  string
+		msg   Message
+		want  error
+		field string
+	}{
+		{"invalid UTF-8", FreeTextMessage{Id: "WSJT-X", Text: "73 \xff"}, InvalidUtf8Error, "Text"},
+		{"string too long", LoggedAdifMessage{Id: "WSJT-X", Adif: long + long + "xx"},
+			StringTooLongError, "Adif"},
+		{"message too long", QsoLoggedMessage{Id: "WSJT-X", Comments: long, Name: long},
+			MessageTooLongError, ""},
+		{"invalid color", HighlightCallsignMessage{Id: "WSJT-X", Callsign: "K1ABC",
+			BackgroundColor: "#ff0000", ForegroundColor: "not a color"},
+			InvalidColorError, "ForegroundColor"},
+		{"DX call", ConfigureMessage{Id: "WSJT-X", DXCall: "<K1ABC>"}, InvalidCallsignError,
+			"DXCall"},
+		{"clear window", ClearMessage{Id: "WSJT-X", Window: 3}, OutOfRangeError, "Window"},
+		{"special operation mode", StatusMessage{Id: "WSJT-X", SpecialOperationMode: 9},
+			OutOfRangeError, "SpecialOperationMode"},
+		{"unknown modifier", ReplyMessage{Id: "WSJT-X", Modifiers: 0x01}, OutOfRangeError,
+			"Modifiers"},
+		{"nil message", nil, EncodeError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Encode(tt.msg)
+			if !errors.Is(err, tt.want) || !errors.Is(err, EncodeError) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+			if got != nil {
+				t.Errorf("got %d bytes along with the error", len(got))
+			}
+			var fieldErr *FieldError
+			if errors.As(err, &fieldErr) != (tt.field != "") {
+				t.Fatalf("error %v isn't a FieldError for %q", err, tt.field)
+			}
+			if fieldErr != nil && fieldErr.Field != tt.field {
+				t.Errorf("field %s, want %s", fieldErr.Field, tt.field)
+			}
+		})
+	}
+
+	// Offsets count from the start of the datagram, even when appending after other data.
+	_, err := Append([]byte("prefix"), ClearMessage{Id: "WSJT-X", Window: 3})
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Offset != 22 || fieldErr.Message != "Clear" {
+		t.Errorf("got %v, want Clear.Window at offset 22", err)
+	}
+	// Every modifier Qt defines is fine.
+	if _, err := Encode(ReplyMessage{Id: "WSJT-X", Modifiers: 0x7e}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,34 @@
+package wsjtx
+
+import "github.com/k0swe/wsjtx-go/v4/band"
+
+// Band returns the band WSJT-X is tuned to, by its dial frequency.
+func (m StatusMessage) Band() (band.Band, bool) {
+	return band.ForFrequency(m.DialFrequency)
+}
+
+// RxFrequency returns the RF frequency WSJT-X is receiving on, the dial frequency plus RxDF.
+func (m StatusMessage) RxFrequency() uint64 {
+	return band.RF(m.DialFrequency, m.RxDF)
+}
+
+// TxFrequency returns the RF frequency WSJT-X transmits on, the dial frequency plus TxDF.
+func (m StatusMessage) TxFrequency() uint64 {
+	return band.RF(m.DialFrequency, m.TxDF)
+}
+
+// DecodeFrequency returns the RF frequency of a decode heard while WSJT-X was at this status'
+// dial frequency.
+func (m StatusMessage) DecodeFrequency(decode DecodeMessage) uint64 {
+	return band.RF(m.DialFrequency, decode.DeltaFrequencyHz)
+}
+
+// Band returns the band of the QSO, by its TxFrequency.
+func (m QsoLoggedMessage) Band() (band.Band, bool) {
+	return band.ForFrequency(m.TxFrequency)
+}
+
+// Band returns the band of the WSPR decode, by its Frequency.
+func (m WSPRDecodeMessage) Band() (band.Band, bool) {
+	return band.ForFrequency(m.Frequency)
+}
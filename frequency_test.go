@@ -0,0 +1,25 @@
+package wsjtx
+
+import "testing"
+
+func TestStatusFrequencies(t *testing.T) {
+	status := StatusMessage{DialFrequency: 14074000, RxDF: 1500, TxDF: 1234}
+	if b, ok := status.Band(); !ok || b.Name != "20m" {
+		t.Errorf("band %v, %v", b, ok)
+	}
+	if got := status.RxFrequency(); got != 14075500 {
+		t.Errorf("rx frequency %d", got)
+	}
+	if got := status.TxFrequency(); got != 14075234 {
+		t.Errorf("tx frequency %d", got)
+	}
+	if got := status.DecodeFrequency(DecodeMessage{DeltaFrequencyHz: 2345}); got != 14076345 {
+		t.Errorf("decode frequency %d", got)
+	}
+	if b, ok := (QsoLoggedMessage{TxFrequency: 144175500}).Band(); !ok || b.Name != "2m" {
+		t.Errorf("qso band %v, %v", b, ok)
+	}
+	if _, ok := (WSPRDecodeMessage{Frequency: 137500}).Band(); ok {
+		t.Error("2200m is outside the bands")
+	}
+}
@@ -0,0 +1,213 @@
+// Package grid works with Maidenhead locators, the grids WSJT-X exchanges in its messages: 2
+// character fields, 4 character squares, 6 character subsquares and 8 character extended squares,
+// e.g. DM, DM79, DM79jx and DM79jx42. Letters may be in either case.
+package grid
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// InvalidError is returned when a locator isn't well formed.
+var InvalidError = errors.New("invalid grid locator")
+
+// EarthRadius is the mean radius of the Earth in kilometers, which distances are based on.
+const EarthRadius = 6371.0
+
+// Point is a place on the Earth, in degrees north and east.
+type Point struct {
+	Lat, Lon float64
+}
+
+// Box is the area a locator covers.
+type Box struct {
+	SouthWest, NorthEast Point
+}
+
+// Center is the middle of the box, which stands for the whole locator when measuring distances.
+func (b Box) Center() Point {
+	return Point{
+		Lat: (b.SouthWest.Lat + b.NorthEast.Lat) / 2,
+		Lon: (b.SouthWest.Lon + b.NorthEast.Lon) / 2,
+	}
+}
+
+// Each pair of characters in a locator divides the one before it, first into 18 by 18 fields
+// named by letters, then 10 by 10 squares named by digits, and so on.
+var pairs = [...]struct {
+	first, count byte
+}{{'A', 18}, {'0', 10}, {'A', 24}, {'0', 10}}
+
+// Valid reports whether the locator is well formed.
+func Valid(locator string) bool {
+	_, err := Bounds(locator)
+	return err == nil
+}
+
+// Bounds returns the area the locator covers.
+func Bounds(locator string) (Box, error) {
+	if len(locator) == 0 || len(locator)%2 != 0 || len(locator) > 2*len(pairs) {
+		return Box{}, fmt.Errorf("%w: %q isn't 2, 4, 6 or 8 characters", InvalidError, locator)
+	}
+	sw := Point{Lat: -90, Lon: -180}
+	width, height := 360.0, 180.0
+	for i := 0; i < len(locator); i += 2 {
+		pair := pairs[i/2]
+		lon, lonOk := index(locator[i], pair.first, pair.count)
+		lat, latOk := index(locator[i+1], pair.first, pair.count)
+		if !lonOk || !latOk {
+			return Box{}, fmt.Errorf("%w: %q", InvalidError, locator)
+		}
+		width /= float64(pair.count)
+		height /= float64(pair.count)
+		sw.Lon += float64(lon) * width
+		sw.Lat += float64(lat) * height
+	}
+	return Box{SouthWest: sw, NorthEast: Point{Lat: sw.Lat + height, Lon: sw.Lon + width}}, nil
+}
+
+// index returns the position of c among the count characters from first, in either case.
+func index(c, first, count byte) (int, bool) {
+	if first == 'A' && c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	if c < first || c >= first+count {
+		return 0, false
+	}
+	return int(c - first), true
+}
+
+// Center returns the middle of the locator.
+func Center(locator string) (Point, error) {
+	b, err := Bounds(locator)
+	if err != nil {
+		return Point{}, err
+	}
+	return b.Center(), nil
+}
+
+// Locator returns the locator of the given length, 2, 4, 6 or 8, which contains the point.
+// Subsquares are in lower case, as WSJT-X writes them, e.g. DM79jx.
+func Locator(p Point, length int) (string, error) {
+	if length <= 0 || length%2 != 0 || length > 2*len(pairs) {
+		return "", fmt.Errorf("%w: can't be %d characters", InvalidError, length)
+	}
+	if math.IsNaN(p.Lat) || math.IsNaN(p.Lon) || p.Lat < -90 || p.Lat > 90 {
+		return "", fmt.Errorf("%w: no locator at %v", InvalidError, p)
+	}
+	lon := math.Mod(p.Lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	// The north pole is in the northernmost row.
+	lat := math.Min(p.Lat+90, math.Nextafter(180, 0))
+	width, height := 360.0, 180.0
+	b := make([]byte, 0, length)
+	for i := 0; i < length/2; i++ {
+		pair := pairs[i]
+		width /= float64(pair.count)
+		height /= float64(pair.count)
+		x, y := int(lon/width), int(lat/height)
+		lon -= float64(x) * width
+		lat -= float64(y) * height
+		first := pair.first
+		if i == 2 {
+			first = 'a'
+		}
+		b = append(b, first+byte(x), first+byte(y))
+	}
+	return string(b), nil
+}
+
+// Neighbors returns the locators of the same size around this one, clockwise from the north.
+// Those which wrap around the antimeridian are included; there are none north of the northernmost
+// row or south of the southernmost.
+func Neighbors(locator string) ([]string, error) {
+	b, err := Bounds(locator)
+	if err != nil {
+		return nil, err
+	}
+	c := b.Center()
+	height := b.NorthEast.Lat - b.SouthWest.Lat
+	width := b.NorthEast.Lon - b.SouthWest.Lon
+	offsets := [...][2]float64{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	neighbors := make([]string, 0, len(offsets))
+	for _, o := range offsets {
+		p := Point{Lat: c.Lat + o[0]*height, Lon: c.Lon + o[1]*width}
+		if p.Lat < -90 || p.Lat > 90 {
+			continue
+		}
+		n, err := Locator(p, len(locator))
+		if err != nil {
+			return nil, err
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, nil
+}
+
+// DistanceTo returns the great circle distance to q in kilometers, along the short path.
+func (p Point) DistanceTo(q Point) float64 {
+	lat1, lat2 := radians(p.Lat), radians(q.Lat)
+	dLat, dLon := lat2-lat1, radians(q.Lon-p.Lon)
+	// Haversine formula, which stays accurate for short distances.
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// BearingTo returns the initial bearing to q along the short path, in degrees clockwise from true
+// north.
+func (p Point) BearingTo(q Point) float64 {
+	lat1, lat2 := radians(p.Lat), radians(q.Lat)
+	dLon := radians(q.Lon - p.Lon)
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+	return math.Mod(degrees(math.Atan2(y, x))+360, 360)
+}
+
+// Distance returns the great circle distance in kilometers between the centers of two locators,
+// along the short path. The long path is the Earth's circumference less this.
+func Distance(from, to string) (float64, error) {
+	p, q, err := centers(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return p.DistanceTo(q), nil
+}
+
+// LongPathDistance returns the distance in kilometers between the centers of two locators the long
+// way around.
+func LongPathDistance(from, to string) (float64, error) {
+	short, err := Distance(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return 2*math.Pi*EarthRadius - short, nil
+}
+
+// Bearing returns the bearings in degrees from the center of one locator to the center of another,
+// along the short path and the long one, which is the opposite direction.
+func Bearing(from, to string) (short, long float64, err error) {
+	p, q, err := centers(from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	short = p.BearingTo(q)
+	return short, math.Mod(short+180, 360), nil
+}
+
+func centers(from, to string) (Point, Point, error) {
+	p, err := Center(from)
+	if err != nil {
+		return Point{}, Point{}, err
+	}
+	q, err := Center(to)
+	if err != nil {
+		return Point{}, Point{}, err
+	}
+	return p, q, nil
+}
+
+func radians(deg float64) float64 { return deg * math.Pi / 180 }
+func degrees(rad float64) float64 { return rad * 180 / math.Pi }
@@ -0,0 +1,138 @@
+package grid
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBounds(t *testing.T) {
+	tests := []struct {
+		locator string
+		want    Box
+	}{
+		{"DM", Box{Point{30, -120}, Point{40, -100}}},
+		{"DM79", Box{Point{39, -106}, Point{40, -104}}},
+		{"dm79JX", Box{Point{39 + 23.0/24, -106 + 9.0/12}, Point{40, -106 + 10.0/12}}},
+		{"DM79jx42", Box{Point{39 + 23.0/24 + 2.0/240, -106 + 9.0/12 + 4.0/120},
+			Point{39 + 23.0/24 + 3.0/240, -106 + 9.0/12 + 5.0/120}}},
+		{"AA00", Box{Point{-90, -180}, Point{-89, -178}}},
+		{"RR99xx99", Box{Point{90 - 1.0/240, 180 - 1.0/120}, Point{90, 180}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locator, func(t *testing.T) {
+			got, err := Bounds(tt.locator)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !near(got.SouthWest, tt.want.SouthWest) || !near(got.NorthEast, tt.want.NorthEast) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, locator := range []string{"", "D", "DM7", "SM79", "DMA9", "DM79yx", "DM79jxA2",
+		"DM79jx4", "DM79jx42aa"} {
+		if _, err := Bounds(locator); !errors.Is(err, InvalidError) {
+			t.Errorf("Bounds(%q) error = %v", locator, err)
+		}
+		if Valid(locator) {
+			t.Errorf("%q is valid", locator)
+		}
+	}
+}
+
+func TestLocator(t *testing.T) {
+	tests := []struct {
+		p      Point
+		length int
+		want   string
+	}{
+		{Point{39.97, -105.27}, 2, "DM"},
+		{Point{39.97, -105.27}, 4, "DM79"},
+		{Point{39.97, -105.27}, 6, "DM79ix"},
+		{Point{39.97, -105.27}, 8, "DM79ix72"},
+		{Point{90, 180}, 4, "AR09"},
+		{Point{-90, -180}, 6, "AA00aa"},
+		{Point{51.5, -540}, 4, "AO01"},
+	}
+	for _, tt := range tests {
+		if got, err := Locator(tt.p, tt.length); err != nil || got != tt.want {
+			t.Errorf("Locator(%v, %d) = %s, %v, want %s", tt.p, tt.length, got, err, tt.want)
+		}
+	}
+	for _, length := range []int{0, 3, 10} {
+		if _, err := Locator(Point{}, length); !errors.Is(err, InvalidError) {
+			t.Errorf("length %d: error = %v", length, err)
+		}
+	}
+	if _, err := Locator(Point{Lat: 91}, 4); !errors.Is(err, InvalidError) {
+		t.Errorf("latitude 91: error = %v", err)
+	}
+}
+
+func TestCenter(t *testing.T) {
+	got, err := Center("DM79")
+	if err != nil || !near(got, Point{39.5, -105}) {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	tests := []struct {
+		locator string
+		want    []string
+	}{
+		{"DM79", []string{"DN70", "DN80", "DM89", "DM88", "DM78", "DM68", "DM69", "DN60"}},
+		{"DM79jx", []string{"DN70ja", "DN70ka", "DM79kx", "DM79kw", "DM79jw", "DM79iw", "DM79ix",
+			"DN70ia"}},
+		{"AR09", []string{"AR19", "AR18", "AR08", "RR98", "RR99"}},
+	}
+	for _, tt := range tests {
+		got, err := Neighbors(tt.locator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Neighbors(%s) = %v, want %v", tt.locator, got, tt.want)
+		}
+	}
+}
+
+func TestDistanceAndBearing(t *testing.T) {
+	tests := []struct {
+		from, to        string
+		distance, short float64
+	}{
+		{"DM79", "FN31", 2699.36, 74.87},
+		{"FN31", "JO62", 6239.95, 47.01},
+		{"DM79", "DM79", 0, 0},
+	}
+	for _, tt := range tests {
+		distance, err := Distance(tt.from, tt.to)
+		if err != nil || math.Abs(distance-tt.distance) > 0.01 {
+			t.Errorf("Distance(%s, %s) = %f, %v, want %f", tt.from, tt.to, distance, err,
+				tt.distance)
+		}
+		long, err := LongPathDistance(tt.from, tt.to)
+		if err != nil || math.Abs(long+distance-2*math.Pi*EarthRadius) > 0.01 {
+			t.Errorf("LongPathDistance(%s, %s) = %f, %v", tt.from, tt.to, long, err)
+		}
+		short, longBearing, err := Bearing(tt.from, tt.to)
+		if err != nil || math.Abs(short-tt.short) > 0.01 ||
+			math.Abs(longBearing-math.Mod(tt.short+180, 360)) > 0.01 {
+			t.Errorf("Bearing(%s, %s) = %f, %f, %v, want %f", tt.from, tt.to, short, longBearing,
+				err, tt.short)
+		}
+	}
+	if _, err := Distance("DM79", "nope"); !errors.Is(err, InvalidError) {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func near(p, q Point) bool {
+	return math.Abs(p.Lat-q.Lat) < 1e-9 && math.Abs(p.Lon-q.Lon) < 1e-9
+}
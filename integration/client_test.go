@@ -0,0 +1,125 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+// clientAndServer makes a server and a client which talks to it, both listening.
+func (s *integrationTestSuite) clientAndServer() (
+	*wsjtx.Client, chan wsjtx.Message, *wsjtx.Server, chan wsjtx.Message) {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	s.Require().NoError(err)
+	serverMsgs := make(chan wsjtx.Message, 5)
+	go server.ListenToWsjtx(serverMsgs, make(chan error, 5))
+	s.T().Cleanup(func() { _ = server.Shutdown(context.Background()) })
+
+	port := server.LocalAddr().(*net.UDPAddr).Port
+	client, err := wsjtx.MakeClientGiven("WSJT-X - fake", net.ParseIP("127.0.0.1"), uint(port))
+	s.Require().NoError(err)
+	client.SetVersion("2.7.0", "abc123")
+	clientMsgs := make(chan wsjtx.Message, 5)
+	go func() { _ = client.Listen(context.Background(), clientMsgs, make(chan error, 5)) }()
+	s.T().Cleanup(func() { _ = client.Shutdown(context.Background()) })
+
+	return client, clientMsgs, server, serverMsgs
+}
+
+func (s *integrationTestSuite) TestClient() {
+	client, clientMsgs, server, serverMsgs := s.clientAndServer()
+
+	// The client introduces itself with a heartbeat as soon as it listens.
+	s.Equal(wsjtx.HeartbeatMessage{
+		Id:        "WSJT-X - fake",
+		MaxSchema: 3,
+		Version:   "2.7.0",
+		Revision:  "abc123",
+	}, <-serverMsgs)
+	info, ok := server.Client("WSJT-X - fake")
+	s.Require().True(ok)
+	s.Equal(client.LocalAddr().(*net.UDPAddr).Port, info.Addr.Port)
+
+	s.Require().NoError(client.Decode(wsjtx.DecodeMessage{New: true, Message: "CQ K0SWE DM79"}))
+	s.Equal(wsjtx.DecodeMessage{Id: "WSJT-X - fake", New: true, Message: "CQ K0SWE DM79"}, <-serverMsgs)
+
+	var replies []wsjtx.ReplyMessage
+	replied := make(chan struct{})
+	client.OnReply(func(m wsjtx.ReplyMessage) {
+		replies = append(replies, m)
+		close(replied)
+	})
+	reply := wsjtx.ReplyMessage{Id: "WSJT-X - fake", Message: "CQ K0SWE DM79", Modifiers: 0x02}
+	s.Require().NoError(server.Reply(reply))
+	s.Equal(reply, <-clientMsgs)
+	<-replied
+	s.Equal([]wsjtx.ReplyMessage{reply}, replies)
+
+	s.Require().NoError(client.Shutdown(context.Background()))
+	s.Equal(wsjtx.CloseMessage{Id: "WSJT-X - fake"}, <-serverMsgs)
+	s.Eventually(func() bool { return len(server.Clients()) == 0 }, time.Second, time.Millisecond)
+}
+
+func (s *integrationTestSuite) TestClientTrailingBytes() {
+	client, clientMsgs, _, serverMsgs := s.clientAndServer()
+	<-serverMsgs
+	trailing := make(chan []byte, 1)
+	client.OnDatagram(func(_ []byte, _ *net.UDPAddr, _ wsjtx.Message, t []byte) {
+		trailing <- t
+	})
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: client.LocalAddr().(*net.UDPAddr).Port,
+	})
+	s.Require().NoError(err)
+	defer conn.Close()
+	msg := wsjtx.HaltTxMessage{Id: "WSJT-X - fake", AutoTxOnly: true}
+	b, err := wsjtx.Encode(msg)
+	s.Require().NoError(err)
+	_, err = conn.Write(append(b, 0x01, 0x02, 0x03))
+	s.Require().NoError(err)
+	s.Equal(msg, <-clientMsgs)
+	s.Equal([]byte{0x01, 0x02, 0x03}, <-trailing)
+}
+
+func (s *integrationTestSuite) TestClientIgnoresOtherIds() {
+	client, clientMsgs, _, serverMsgs := s.clientAndServer()
+	<-serverMsgs
+
+	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: client.LocalAddr().(*net.UDPAddr).Port,
+	})
+	s.Require().NoError(err)
+	defer conn.Close()
+	for _, msg := range []wsjtx.Message{
+		wsjtx.HaltTxMessage{Id: "WSJT-X - other"},
+		wsjtx.HaltTxMessage{Id: "WSJT-X - fake", AutoTxOnly: true},
+	} {
+		b, err := wsjtx.Encode(msg)
+		s.Require().NoError(err)
+		_, err = conn.Write(b)
+		s.Require().NoError(err)
+	}
+	s.Equal(wsjtx.HaltTxMessage{Id: "WSJT-X - fake", AutoTxOnly: true}, <-clientMsgs)
+
+	err = client.Send(wsjtx.StatusMessage{Id: "WSJT-X - other"})
+	s.Error(err)
+}
+
+func (s *integrationTestSuite) TestClientNegotiatesSchema() {
+	client, clientMsgs, server, serverMsgs := s.clientAndServer()
+	<-serverMsgs
+	s.Equal(uint32(2), client.Schema())
+
+	s.Require().NoError(server.Heartbeat(wsjtx.HeartbeatMessage{Id: "WSJT-X - fake", MaxSchema: 3}))
+	<-clientMsgs
+	s.Equal(uint32(3), client.Schema())
+
+	s.Require().NoError(server.Heartbeat(wsjtx.HeartbeatMessage{Id: "WSJT-X - fake", MaxSchema: 1}))
+	<-clientMsgs
+	s.Equal(uint32(1), client.Schema())
+}
@@ -0,0 +1,127 @@
+package integration
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+func (s *integrationTestSuite) TestRouteById() {
+	other, err := NewFake(s.fake.conn.RemoteAddr().(*net.UDPAddr), s.T())
+	s.Require().NoError(err)
+	defer other.Stop()
+
+	_, err = s.fake.SendMessage(clearFrom("WSJT-X - rig1"))
+	s.Require().NoError(err)
+	<-s.msgChan
+	_, err = other.SendMessage(clearFrom("WSJT-X - rig2"))
+	s.Require().NoError(err)
+	<-s.msgChan
+
+	rig1, ok := s.server.Client("WSJT-X - rig1")
+	s.Require().True(ok)
+	s.Equal(s.fake.conn.LocalAddr().String(), rig1.Addr.String())
+	rig2, ok := s.server.Client("WSJT-X - rig2")
+	s.Require().True(ok)
+	s.Equal(other.conn.LocalAddr().String(), rig2.Addr.String())
+
+	s.T().Log("sending replay to rig2")
+	err = s.server.Replay(wsjtx.ReplayMessage{Id: "WSJT-X - rig2"})
+	s.Require().NoError(err)
+	select {
+	case got := <-other.ReceiveChan:
+		s.Equal(replayFor("WSJT-X - rig2"), got)
+	case got := <-s.fake.ReceiveChan:
+		s.Failf("wrong client", "rig1 received %x", got)
+	case <-time.After(50 * time.Millisecond):
+		s.Fail("timeout")
+	}
+
+	err = s.server.Replay(wsjtx.ReplayMessage{Id: "WSJT-X - rig3"})
+	s.ErrorIs(err, wsjtx.NotConnectedError)
+}
+
+func (s *integrationTestSuite) TestClientEvents() {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	s.Require().NoError(err)
+	defer server.Shutdown(context.Background())
+	msgChan := make(chan wsjtx.Message, 5)
+	errChan := make(chan error, 5)
+	events := make(chan wsjtx.ClientEvent, 5)
+	server.WatchClients(events)
+	go server.ListenToWsjtx(msgChan, errChan)
+
+	fake, err := NewFake(server.LocalAddr().(*net.UDPAddr), s.T())
+	s.Require().NoError(err)
+	defer fake.Stop()
+
+	_, err = fake.SendMessage(decode(`adbccbda00000002000000000000000657534a542d580000000300000005322e322e3200000006306439623936`))
+	s.Require().NoError(err)
+	event := <-events
+	s.Equal(wsjtx.ClientConnected, event.Type)
+	s.Equal("WSJT-X", event.Client.Id)
+	s.Equal("2.2.2", event.Client.Version)
+	s.Equal(uint32(3), event.Client.MaxSchema)
+	s.Len(server.Clients(), 1)
+
+	_, err = fake.SendMessage(decode(`adbccbda00000002000000060000000657534a542d58`))
+	s.Require().NoError(err)
+	event = <-events
+	s.Equal(wsjtx.ClientDisconnected, event.Type)
+	s.Equal("WSJT-X", event.Client.Id)
+	s.Empty(server.Clients())
+}
+
+// clearFrom builds a Clear message as sent by the WSJT-X instance with the given Id.
+func clearFrom(id string) []byte {
+	return withId(decode(`adbccbda0000000200000003`), id)
+}
+
+// replayFor builds a Replay message addressed to the WSJT-X instance with the given Id.
+func replayFor(id string) []byte {
+	return withId(decode(`adbccbda0000000200000007`), id)
+}
+
+func withId(header []byte, id string) []byte {
+	b := binary.BigEndian.AppendUint32(header, uint32(len(id)))
+	return append(b, id...)
+}
+
+func (s *integrationTestSuite) TestNegotiateSchema() {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	s.Require().NoError(err)
+	defer server.Shutdown(context.Background())
+	msgChan := make(chan wsjtx.Message, 5)
+	go server.ListenToWsjtx(msgChan, make(chan error, 5))
+
+	fake, err := NewFake(server.LocalAddr().(*net.UDPAddr), s.T())
+	s.Require().NoError(err)
+	defer fake.Stop()
+
+	// Before a heartbeat, replies use the schema the client is sending with.
+	_, err = fake.SendMessage(decode(`adbccbda00000003000000030000000657534a542d58`))
+	s.Require().NoError(err)
+	<-msgChan
+	client, _ := server.Client("WSJT-X")
+	s.Equal(uint32(3), client.Schema)
+
+	// A heartbeat advertising schema 2 negotiates down to 2 even though it was sent with 3.
+	_, err = fake.SendMessage(decode(`adbccbda00000003000000000000000657534a542d580000000200000005322e322e3200000006306439623936`))
+	s.Require().NoError(err)
+	<-msgChan
+	client, _ = server.Client("WSJT-X")
+	s.Equal(uint32(2), client.MaxSchema)
+	s.Equal(uint32(2), client.Schema)
+	s.Require().NoError(server.Replay(wsjtx.ReplayMessage{Id: "WSJT-X"}))
+	s.Equal(decode(`adbccbda00000002000000070000000657534a542d58`), <-fake.ReceiveChan)
+
+	// A heartbeat advertising a schema newer than ours negotiates down to ours.
+	_, err = fake.SendMessage(decode(`adbccbda00000002000000000000000657534a542d580000000900000005322e372e3000000006306439623936`))
+	s.Require().NoError(err)
+	<-msgChan
+	s.Require().NoError(server.Replay(wsjtx.ReplayMessage{Id: "WSJT-X"}))
+	s.Equal(decode(`adbccbda00000003000000070000000657534a542d58`), <-fake.ReceiveChan)
+}
@@ -0,0 +1,84 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+func (s *integrationTestSuite) TestLargeDatagram() {
+	adif := wsjtx.LoggedAdifMessage{
+		Id:   "WSJT-X",
+		Adif: "<call:5>K0SWE <comment:6000>" + strings.Repeat("73 ", 2000) + "<eor>",
+	}
+	b, err := wsjtx.Encode(adif)
+	s.Require().NoError(err)
+	s.Greater(len(b), 6000)
+	_, err = s.fake.SendMessage(b)
+	s.Require().NoError(err)
+	s.Equal(adif, <-s.msgChan)
+}
+
+func (s *integrationTestSuite) TestTruncatedDatagram() {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0),
+		wsjtx.WithDatagramSize(512))
+	s.Require().NoError(err)
+	defer server.Shutdown(context.Background())
+	var datagrams [][]byte
+	server.OnDatagram(func(datagram []byte, _ *net.UDPAddr, _ wsjtx.Message, _ []byte) {
+		datagrams = append(datagrams, datagram)
+	})
+	msgs, errs := server.Serve(context.Background())
+
+	fake, err := NewFake(server.LocalAddr().(*net.UDPAddr), s.T())
+	s.Require().NoError(err)
+	defer fake.Stop()
+	long, err := wsjtx.Encode(wsjtx.LoggedAdifMessage{Id: "WSJT-X", Adif: strings.Repeat("x", 512)})
+	s.Require().NoError(err)
+	_, err = fake.SendMessage(long)
+	s.Require().NoError(err)
+	err = <-errs
+	s.True(errors.Is(err, wsjtx.DatagramTruncatedError), "%v", err)
+
+	// The server carries on, and each datagram handed to a handler is its own copy.
+	first, err := wsjtx.Encode(wsjtx.FreeTextMessage{Id: "WSJT-X", Text: "first"})
+	s.Require().NoError(err)
+	second, err := wsjtx.Encode(wsjtx.FreeTextMessage{Id: "WSJT-X", Text: "later"})
+	s.Require().NoError(err)
+	for _, b := range [][]byte{first, second} {
+		_, err = fake.SendMessage(b)
+		s.Require().NoError(err)
+		<-msgs
+	}
+	s.Equal([][]byte{first, second}, datagrams)
+}
+
+func (s *integrationTestSuite) TestTrailingBytes() {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	s.Require().NoError(err)
+	defer server.Shutdown(context.Background())
+	trailing := make(chan []byte, 1)
+	server.OnDatagram(func(_ []byte, _ *net.UDPAddr, _ wsjtx.Message, t []byte) {
+		trailing <- t
+	})
+	msgs, _ := server.Serve(context.Background())
+
+	fake, err := NewFake(server.LocalAddr().(*net.UDPAddr), s.T())
+	s.Require().NoError(err)
+	defer fake.Stop()
+	msg := wsjtx.ClearMessage{Id: "WSJT-X", Window: 1}
+	b, err := wsjtx.Encode(msg)
+	s.Require().NoError(err)
+	_, err = fake.SendMessage(append(b, 0xca, 0xfe))
+	s.Require().NoError(err)
+	s.Equal(msg, <-msgs)
+	s.Equal([]byte{0xca, 0xfe}, <-trailing)
+
+	_, err = fake.SendMessage(b)
+	s.Require().NoError(err)
+	<-msgs
+	s.Nil(<-trailing)
+}
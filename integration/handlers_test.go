@@ -0,0 +1,74 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+func (s *integrationTestSuite) TestHandlers() {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	s.Require().NoError(err)
+	defer server.Shutdown(context.Background())
+
+	decodes := make(chan wsjtx.DecodeMessage, 1)
+	errs := make(chan error, 1)
+	server.Use(wsjtx.Recover(nil))
+	server.OnDecode(func(m wsjtx.DecodeMessage) { decodes <- m })
+	server.OnError(func(err error) { errs <- err })
+	go server.Listen(context.Background(), nil, nil)
+
+	fake, err := NewFake(server.LocalAddr().(*net.UDPAddr), s.T())
+	s.Require().NoError(err)
+	defer fake.Stop()
+
+	_, err = fake.SendMessage(decode(`adbccbda00000002000000020000000657534a542d58010259baf8fffffffb3fc99999a000000000000516000000017e0000000e4a4132454a50204e3442502037330000`))
+	s.Require().NoError(err)
+	select {
+	case m := <-decodes:
+		s.Equal("JA2EJP N4BP 73", m.Message)
+	case <-time.After(50 * time.Millisecond):
+		s.Fail("timeout waiting for decode handler")
+	}
+
+	_, err = fake.SendMessage(decode(`deadbeef`))
+	s.Require().NoError(err)
+	select {
+	case err := <-errs:
+		s.ErrorIs(err, wsjtx.ParseError)
+	case <-time.After(50 * time.Millisecond):
+		s.Fail("timeout waiting for error handler")
+	}
+}
+
+func (s *integrationTestSuite) TestReceiveUnknown() {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	s.Require().NoError(err)
+	defer server.Shutdown(context.Background())
+	msgChan := make(chan wsjtx.Message, 5)
+	errChan := make(chan error, 5)
+	go server.ListenToWsjtx(msgChan, errChan)
+
+	fake, err := NewFake(server.LocalAddr().(*net.UDPAddr), s.T())
+	s.Require().NoError(err)
+	defer fake.Stop()
+
+	_, err = fake.SendMessage(decode(`adbccbda00000002000000630000000657534a542d58cafe`))
+	s.Require().NoError(err)
+	select {
+	case msg := <-msgChan:
+		s.Equal(wsjtx.UnknownMessage{
+			Id:         "WSJT-X",
+			TypeNumber: 99,
+			Payload:    decode(`0000000657534a542d58cafe`),
+		}, msg)
+	case err := <-errChan:
+		s.Fail("unexpected error", err)
+	case <-time.After(50 * time.Millisecond):
+		s.Fail("timeout")
+	}
+	_, known := server.Client("WSJT-X")
+	s.True(known)
+}
@@ -0,0 +1,71 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+func (s *integrationTestSuite) TestListenStopsOnCancel() {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	s.Require().NoError(err)
+	defer server.Shutdown(context.Background())
+
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		msgChan := make(chan wsjtx.Message, 5)
+		errChan := make(chan error, 5)
+		result := make(chan error, 1)
+		go func() { result <- server.Listen(ctx, msgChan, errChan) }()
+		s.Eventually(server.Listening, time.Second, time.Millisecond)
+
+		cancel()
+		select {
+		case err := <-result:
+			s.NoError(err)
+		case <-time.After(time.Second):
+			s.FailNow("Listen didn't return after cancel")
+		}
+		s.False(server.Listening())
+		_, open := <-msgChan
+		s.False(open)
+		_, open = <-errChan
+		s.False(open)
+	}
+}
+
+func (s *integrationTestSuite) TestListenTwice() {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	s.Require().NoError(err)
+	defer server.Shutdown(context.Background())
+	go server.ListenToWsjtx(make(chan wsjtx.Message, 5), make(chan error, 5))
+	s.Eventually(server.Listening, time.Second, time.Millisecond)
+
+	errChan := make(chan error, 5)
+	err = server.Listen(context.Background(), make(chan wsjtx.Message, 5), errChan)
+	s.ErrorIs(err, wsjtx.AlreadyListeningError)
+	s.ErrorIs(<-errChan, wsjtx.AlreadyListeningError)
+}
+
+func (s *integrationTestSuite) TestShutdown() {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	s.Require().NoError(err)
+	msgChan := make(chan wsjtx.Message, 5)
+	errChan := make(chan error, 5)
+	result := make(chan error, 1)
+	go func() { result <- server.Listen(context.Background(), msgChan, errChan) }()
+	s.Eventually(server.Listening, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Require().NoError(server.Shutdown(ctx))
+	s.NoError(<-result)
+	_, open := <-errChan
+	s.False(open, "expected no error to be reported")
+	s.NoError(server.Shutdown(ctx))
+
+	err = server.Listen(context.Background(), make(chan wsjtx.Message), make(chan error, 1))
+	s.ErrorIs(err, wsjtx.ServerClosedError)
+}
@@ -0,0 +1,66 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+func (s *integrationTestSuite) TestLiveness() {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	s.Require().NoError(err)
+	defer server.Shutdown(context.Background())
+	server.SetLiveness(20*time.Millisecond, 2, 5)
+	events := make(chan wsjtx.ClientEvent, 5)
+	server.WatchClients(events)
+	go server.ListenToWsjtx(nil, nil)
+
+	fake, err := NewFake(server.LocalAddr().(*net.UDPAddr), s.T())
+	s.Require().NoError(err)
+	defer fake.Stop()
+
+	_, err = fake.SendMessage(clearFrom("WSJT-X"))
+	s.Require().NoError(err)
+	s.Equal(wsjtx.ClientConnected, (<-events).Type)
+
+	event := <-events
+	s.Equal(wsjtx.ClientStale, event.Type)
+	s.True(event.Client.Stale)
+	client, _ := server.Client("WSJT-X")
+	s.True(client.Stale)
+
+	_, err = fake.SendMessage(clearFrom("WSJT-X"))
+	s.Require().NoError(err)
+	event = <-events
+	s.Equal(wsjtx.ClientRevived, event.Type)
+	s.False(event.Client.Stale)
+
+	s.Equal(wsjtx.ClientStale, (<-events).Type)
+	event = <-events
+	s.Equal(wsjtx.ClientDisconnected, event.Type)
+	s.Equal("WSJT-X", event.Client.Id)
+	s.Empty(server.Clients())
+}
+
+func (s *integrationTestSuite) TestServerHeartbeats() {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	s.Require().NoError(err)
+	defer server.Shutdown(context.Background())
+	server.SetHeartbeatInterval(10 * time.Millisecond)
+	server.SetVersion("1.0", "test")
+	go server.ListenToWsjtx(nil, nil)
+
+	client, err := wsjtx.MakeClientGiven("WSJT-X", net.ParseIP("127.0.0.1"),
+		uint(server.LocalAddr().(*net.UDPAddr).Port))
+	s.Require().NoError(err)
+	defer client.Shutdown(context.Background())
+	client.SetHeartbeatInterval(time.Hour)
+	msgs := make(chan wsjtx.Message, 5)
+	go func() { _ = client.Listen(context.Background(), msgs, nil) }()
+
+	s.Equal(wsjtx.HeartbeatMessage{Id: "WSJT-X", MaxSchema: 3, Version: "1.0", Revision: "test"},
+		<-msgs)
+	s.Equal(uint32(3), client.Schema())
+}
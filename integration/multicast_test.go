@@ -0,0 +1,43 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+func (s *integrationTestSuite) TestMulticast() {
+	// Port 0 means WSJT-X's port, which may be in use, so find a free one.
+	probe, err := net.ListenUDP("udp4", nil)
+	s.Require().NoError(err)
+	port := uint(probe.LocalAddr().(*net.UDPAddr).Port)
+	s.Require().NoError(probe.Close())
+
+	server, err := wsjtx.NewServer(wsjtx.WithMulticast(wsjtx.MulticastOptions{Port: port}))
+	if err != nil {
+		s.T().Skipf("multicast isn't available here: %v", err)
+	}
+	defer server.Shutdown(context.Background())
+	serverMsgs := make(chan wsjtx.Message, 5)
+	go server.ListenToWsjtx(serverMsgs, nil)
+
+	client, err := wsjtx.MakeMulticastClient("WSJT-X", wsjtx.MulticastOptions{
+		Port: port,
+		TTL:  1,
+	})
+	s.Require().NoError(err)
+	defer client.Shutdown(context.Background())
+	clientMsgs := make(chan wsjtx.Message, 5)
+	go func() { _ = client.Listen(context.Background(), clientMsgs, nil) }()
+
+	select {
+	case msg := <-serverMsgs:
+		s.Equal(wsjtx.HeartbeatType, msg.Type())
+	case <-time.After(time.Second):
+		s.T().Skip("multicast datagrams aren't delivered here")
+	}
+	s.Require().NoError(server.Replay(wsjtx.ReplayMessage{Id: "WSJT-X"}))
+	s.Equal(wsjtx.ReplayMessage{Id: "WSJT-X"}, <-clientMsgs)
+}
@@ -0,0 +1,65 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+func (s *integrationTestSuite) TestNewServerOptions() {
+	seen := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	var logged bytes.Buffer
+	server, err := wsjtx.NewServer(
+		wsjtx.WithAddress(net.ParseIP("127.0.0.1")),
+		wsjtx.WithPort(0),
+		wsjtx.WithClock(func() time.Time { return seen }),
+		wsjtx.WithReadTimeout(50*time.Millisecond),
+		wsjtx.WithLogger(log.New(&logged, "", 0)),
+		wsjtx.WithMaxSchema(2),
+		wsjtx.WithChannelSizes(1, 1),
+	)
+	s.Require().NoError(err)
+	defer server.Shutdown(context.Background())
+	msgs, errs := server.Serve(context.Background())
+
+	fake, err := NewFake(server.LocalAddr().(*net.UDPAddr), s.T())
+	s.Require().NoError(err)
+	defer fake.Stop()
+	heartbeat, err := wsjtx.Encode(wsjtx.HeartbeatMessage{Id: "WSJT-X", MaxSchema: 3})
+	s.Require().NoError(err)
+	_, err = fake.SendMessage(heartbeat)
+	s.Require().NoError(err)
+
+	s.Equal(wsjtx.HeartbeatMessage{Id: "WSJT-X", MaxSchema: 3}, <-msgs)
+	client, ok := server.Client("WSJT-X")
+	s.Require().True(ok)
+	s.Equal(seen, client.LastSeen)
+	s.Equal(uint32(2), client.Schema)
+
+	// Nothing more is sent, so the read timeout passes without stopping the server.
+	err = <-errs
+	s.True(errors.Is(err, wsjtx.ReadTimeoutError), "%v", err)
+	s.Contains(logged.String(), "nothing heard from wsjtx")
+	s.True(server.Listening())
+}
+
+func (s *integrationTestSuite) TestNewServerRejectsBadOptions() {
+	for name, opt := range map[string]wsjtx.Option{
+		"schema":       wsjtx.WithMaxSchema(4),
+		"port":         wsjtx.WithPort(65536),
+		"datagram":     wsjtx.WithDatagramSize(0),
+		"clock":        wsjtx.WithClock(nil),
+		"read timeout": wsjtx.WithReadTimeout(-time.Second),
+		"channels":     wsjtx.WithChannelSizes(-1, 0),
+	} {
+		_, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0), opt)
+		s.Error(err, name)
+	}
+	_, err := wsjtx.NewServer(wsjtx.WithMulticast(wsjtx.MulticastOptions{}), wsjtx.WithPort(0))
+	s.Error(err)
+}
@@ -15,7 +15,7 @@ type receiveCase struct {
 }
 
 type receiveResult struct {
-	msg interface{}
+	msg wsjtx.Message
 	err error
 }
 
@@ -32,6 +32,7 @@ func (s *integrationTestSuite) TestReceiveCases() {
 			}, nil},
 		},
 		{
+			// Captured from WSJT-X 2.2.2, which predates the TxMessage field.
 			name: "Status 2.2.2",
 			args: decode(`adbccbda00000002000000010000000657534a542d5800000000006bf0d000000003465438ffffffff000000032d313500000003465438000000000003730000079e000000054b3053574500000006444d37394c56ffffffff00ffffffff0000ffffffffffffffff0000000744656661756c74`),
 			want: receiveResult{wsjtx.StatusMessage{
@@ -56,7 +57,7 @@ func (s *integrationTestSuite) TestReceiveCases() {
 				FrequencyTolerance:   4294967295,
 				TRPeriod:             4294967295,
 				ConfigurationName:    "Default",
-			}, wsjtx.ParseError},
+			}, nil},
 		},
 		{
 			name: "Status 2.3.1",
@@ -114,7 +115,7 @@ func (s *integrationTestSuite) TestReceiveCases() {
 			args: decode(`adbccbda00000002000000050000000657534a542d5800000000002586110277ac48010000000454335354000000044a4b373300000000006bf86e00000003465438000000022d33000000022d37000000013500000007436f6d6d656e74000000034a6f6500000000002586110276c1e801000000055433535452000000054b3053574500000006444d37394c5600000002314200000002314400000003494f4e`),
 			want: receiveResult{wsjtx.QsoLoggedMessage{
 				Id:                  "WSJT-X",
-				DateTimeOff:         parseTime("2020-10-30 11:29:57 +0000 UTC"),
+				DateTimeOff:         parseTime("2020-10-30 11:29:57.32 +0000 UTC"),
 				DxCall:              "T3ST",
 				DxGrid:              "JK73",
 				TxFrequency:         7075950,
@@ -124,7 +125,7 @@ func (s *integrationTestSuite) TestReceiveCases() {
 				TxPower:             "5",
 				Comments:            "Comment",
 				Name:                "Joe",
-				DateTimeOn:          parseTime("2020-10-30 11:28:57 +0000 UTC"),
+				DateTimeOn:          parseTime("2020-10-30 11:28:57.32 +0000 UTC"),
 				OperatorCall:        "T3STR",
 				MyCall:              "K0SWE",
 				MyGrid:              "DM79LV",
@@ -169,6 +170,95 @@ func (s *integrationTestSuite) TestReceiveCases() {
 <call:4>T3ST <gridsquare:4>JK73 <mode:3>FT8 <rst_sent:2>-8 <rst_rcvd:2>-9 <qso_date:8>20201030 <time_on:6>120816 <qso_date_off:8>20201030 <time_off:6>120916 <band:3>40m <freq:8>7.075950 <station_callsign:5>K0SWE <my_gridsquare:6>DM79LV <tx_pwr:1>5 <comment:7>Comment <name:4>Jess <operator:5>T3STR <EOR>`,
 			}, nil},
 		},
+		s.roundTrip("Heartbeat round trip", wsjtx.HeartbeatMessage{
+			Id:        "WSJT-X",
+			MaxSchema: 2,
+			Version:   "2.2.2",
+			Revision:  "0d9b96",
+		}),
+		s.roundTrip("Status round trip", wsjtx.StatusMessage{
+			Id:                   "WSJT-X",
+			DialFrequency:        7074000,
+			Mode:                 "FT8",
+			DxCall:               "T3ST",
+			Report:               "-15",
+			TxMode:               "FT8",
+			TxEnabled:            true,
+			Transmitting:         false,
+			Decoding:             true,
+			RxDF:                 883,
+			TxDF:                 1950,
+			DeCall:               "K0SWE",
+			DeGrid:               "DM79LV",
+			DxGrid:               "JK73",
+			TxWatchdog:           false,
+			SubMode:              "",
+			FastMode:             false,
+			SpecialOperationMode: 0,
+			FrequencyTolerance:   4294967295,
+			TRPeriod:             4294967295,
+			ConfigurationName:    "Default",
+			TxMessage:            "T3ST K0SWE DM79",
+		}),
+		s.roundTrip("Decode round trip", wsjtx.DecodeMessage{
+			Id:               "WSJT-X",
+			New:              true,
+			Time:             39435000,
+			Snr:              -5,
+			DeltaTimeSec:     0.20000000298023224,
+			DeltaFrequencyHz: 1302,
+			Mode:             "~",
+			Message:          "JA2EJP N4BP 73",
+			LowConfidence:    false,
+			OffAir:           false,
+		}),
+		s.roundTrip("Clear round trip", wsjtx.ClearMessage{
+			Id: "WSJT-X",
+		}),
+		s.roundTrip("QSO Logged round trip", wsjtx.QsoLoggedMessage{
+			Id:                  "WSJT-X",
+			DateTimeOff:         parseTime("2020-10-30 11:29:57 +0000 UTC"),
+			DxCall:              "T3ST",
+			DxGrid:              "JK73",
+			TxFrequency:         7075950,
+			Mode:                "FT8",
+			ReportSent:          "-3",
+			ReportReceived:      "-7",
+			TxPower:             "5",
+			Comments:            "Comment",
+			Name:                "Joe",
+			DateTimeOn:          parseTime("2020-10-30 11:28:57 +0000 UTC"),
+			OperatorCall:        "T3STR",
+			MyCall:              "K0SWE",
+			MyGrid:              "DM79LV",
+			ExchangeSent:        "1B",
+			ExchangeReceived:    "1D",
+			ADIFPropagationMode: "ION",
+		}),
+		s.roundTrip("Close round trip", wsjtx.CloseMessage{
+			Id: "WSJT-X",
+		}),
+		s.roundTrip("WSPR Decode round trip", wsjtx.WSPRDecodeMessage{
+			Id:        "WSJT-X",
+			New:       true,
+			Time:      45480000,
+			Snr:       -18,
+			DeltaTime: -0.5,
+			Frequency: 7040115,
+			Drift:     0,
+			Callsign:  "K6TGW",
+			Grid:      "CM95",
+			Power:     23,
+			OffAir:    false,
+		}),
+		s.roundTrip("Logged Adif round trip", wsjtx.LoggedAdifMessage{
+			Id: "WSJT-X",
+			Adif: `
+<adif_ver:5>3.1.0
+<programid:6>WSJT-X
+<EOH>
+<call:4>T3ST <gridsquare:4>JK73 <mode:3>FT8 <rst_sent:2>-8 <rst_rcvd:2>-9 <qso_date:8>20201030 <time_on:6>120816 <qso_date_off:8>20201030 <time_off:6>120916 <band:3>40m <freq:8>7.075950 <station_callsign:5>K0SWE <my_gridsquare:6>DM79LV <tx_pwr:1>5 <comment:7>Comment <name:4>Jess <operator:5>T3STR <EOR>`,
+		}),
 	}
 
 	for _, tt := range tests {
@@ -176,6 +266,14 @@ func (s *integrationTestSuite) TestReceiveCases() {
 	}
 }
 
+// roundTrip makes a case where the fake sends the encoded message and the server should parse the
+// same message back out.
+func (s *integrationTestSuite) roundTrip(name string, msg wsjtx.Message) receiveCase {
+	b, err := wsjtx.Encode(msg)
+	s.Require().NoError(err)
+	return receiveCase{name: name, args: b, want: receiveResult{msg, nil}}
+}
+
 func (s *integrationTestSuite) runReceiveTest(tt receiveCase) {
 	s.T().Run(tt.name, func(t *testing.T) {
 		msgPassed, errPassed := false, false
@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"errors"
 	"math"
 	"time"
 
@@ -64,7 +65,7 @@ func (s *integrationTestSuite) TestSendClose() {
 	want := decode(`adbccbda00000002000000060000000657534a542d58`)
 
 	s.T().Log("sending close struct")
-	err := s.server.Close(msg)
+	err := s.server.CloseClient(msg)
 	s.Require().NoError(err)
 	s.waitForReceiveAndCheck(want)
 }
@@ -175,6 +176,23 @@ func (s *integrationTestSuite) TestSendConfigure() {
 	s.waitForReceiveAndCheck(want)
 }
 
+func (s *integrationTestSuite) TestSendAnnotationInfo() {
+	s.primeConnection()
+
+	msg := wsjtx.AnnotationInfoMessage{
+		Id:                "WSJT-X",
+		DxCall:            "K1ABC",
+		SortOrderProvided: true,
+		SortOrder:         5,
+	}
+	want := decode(`adbccbda00000002000000100000000657534a542d58000000054b314142430100000005`)
+
+	s.T().Log("sending annotationInfo struct")
+	err := s.server.AnnotationInfo(msg)
+	s.Require().NoError(err)
+	s.waitForReceiveAndCheck(want)
+}
+
 func (s *integrationTestSuite) waitForReceiveAndCheck(want []byte) {
 	for {
 		select {
@@ -198,3 +216,26 @@ func (s *integrationTestSuite) primeConnection() {
 	<-s.msgChan
 	s.T().Log("connection is primed for a send test")
 }
+
+func (s *integrationTestSuite) TestSendInvalid() {
+	s.primeConnection()
+
+	err := s.server.HighlightCallsign(wsjtx.HighlightCallsignMessage{
+		Id:              "WSJT-X",
+		Callsign:        "K1ABC",
+		BackgroundColor: "#ff0000",
+		ForegroundColor: "nope",
+	})
+	s.True(errors.Is(err, wsjtx.InvalidColorError), "%v", err)
+	var fieldErr *wsjtx.FieldError
+	s.Require().True(errors.As(err, &fieldErr))
+	s.Equal("ForegroundColor", fieldErr.Field)
+
+	s.True(errors.Is(s.server.Clear(wsjtx.ClearMessage{Id: "WSJT-X", Window: 3}),
+		wsjtx.OutOfRangeError))
+	select {
+	case got := <-s.fake.ReceiveChan:
+		s.Failf("sent an invalid message", "%x", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
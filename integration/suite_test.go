@@ -11,8 +11,8 @@ import (
 
 type integrationTestSuite struct {
 	suite.Suite
-	server  wsjtx.Server
-	msgChan chan interface{}
+	server  *wsjtx.Server
+	msgChan chan wsjtx.Message
 	errChan chan error
 	fake    *WsjtxFake
 }
@@ -23,9 +23,9 @@ func TestIntegrationSuite(t *testing.T) {
 
 func (s *integrationTestSuite) SetupSuite() {
 	var err error
-	s.msgChan = make(chan interface{}, 5)
+	s.msgChan = make(chan wsjtx.Message, 5)
 	s.errChan = make(chan error, 5)
-	s.server, err = wsjtx.MakeServerGiven(net.ParseIP("127.0.0.1"), 0)
+	s.server, err = wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
 	s.Require().NoError(err)
 	go s.server.ListenToWsjtx(s.msgChan, s.errChan)
 	s.T().Log("suite started server listening")
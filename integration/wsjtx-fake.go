@@ -17,6 +17,7 @@ type WsjtxFake struct {
 	conn        *net.UDPConn
 	ReceiveChan chan []byte
 	stop        chan bool
+	stopped     chan struct{}
 }
 
 // NewFake initializes a new fake WSJTX program on an OS-assigned port.
@@ -27,7 +28,7 @@ func NewFake(addr *net.UDPAddr, t *testing.T) (*WsjtxFake, error) {
 	}
 	t.Logf("fake is connected to %v", conn.RemoteAddr())
 
-	w := &WsjtxFake{t, conn, make(chan []byte, 5), make(chan bool, 1)}
+	w := &WsjtxFake{t, conn, make(chan []byte, 5), make(chan bool, 1), make(chan struct{})}
 	go w.handleReceive()
 	return w, nil
 }
@@ -47,9 +48,10 @@ func (w *WsjtxFake) handleReceive() {
 			w.t.Log("stopping")
 			close(w.ReceiveChan)
 			_ = w.conn.Close()
+			close(w.stopped)
 			return
 		default:
-			_ = w.conn.SetDeadline(time.Now().Add(1 * time.Millisecond))
+			_ = w.conn.SetReadDeadline(time.Now().Add(1 * time.Millisecond))
 			n, err := w.conn.Read(b)
 			if err != nil {
 				if err != io.EOF && !errors.Is(err, os.ErrDeadlineExceeded) {
@@ -66,6 +68,8 @@ func (w *WsjtxFake) handleReceive() {
 	}
 }
 
+// Stop shuts down the fake and waits for its receive goroutine to finish.
 func (w *WsjtxFake) Stop() {
 	w.stop <- true
+	<-w.stopped
 }
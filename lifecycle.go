@@ -0,0 +1,121 @@
+package wsjtx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var AlreadyListeningError = errors.New("wsjtx server is already listening")
+var ServerClosedError = errors.New("wsjtx server has been shut down")
+
+// runState coordinates the listen loop with Shutdown. It's shared by pointer so that copies of a
+// Server all see the same lifecycle.
+type runState struct {
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	done     chan struct{}
+	shutdown bool
+}
+
+// start marks the listen loop as running, returning a context which is cancelled on Shutdown and a
+// function to call when the loop exits.
+func (r *runState) start(ctx context.Context) (context.Context, func(), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.shutdown {
+		return nil, nil, ServerClosedError
+	}
+	if r.done != nil {
+		return nil, nil, AlreadyListeningError
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	r.cancel, r.done = cancel, done
+	return ctx, func() {
+		cancel()
+		r.mu.Lock()
+		r.cancel, r.done = nil, nil
+		r.mu.Unlock()
+		close(done)
+	}, nil
+}
+
+func (r *runState) running() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done != nil
+}
+
+func (r *runState) closed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.shutdown
+}
+
+// stop marks the server as shut down and cancels the listen loop, returning a channel which is
+// closed once the loop has exited. The boolean is false if stop had already been called.
+func (r *runState) stop() (<-chan struct{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	first := !r.shutdown
+	r.shutdown = true
+	if r.done == nil {
+		return closedChan, first
+	}
+	r.cancel()
+	return r.done, first
+}
+
+// closedChan is a channel which is always closed, for things which are already done.
+var closedChan = func() <-chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// aLongTimeAgo is a read deadline which makes a blocked read return immediately.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// unblockReads returns a context derived from ctx, and arranges for reads on conn to return once
+// that context is done; a blocked read doesn't notice the context by itself. The returned function
+// cancels the context and waits until the read deadline has been set, so it can't interfere with a
+// later listener.
+func unblockReads(ctx context.Context, conn *net.UDPConn) (context.Context, func(), error) {
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	unblocked := make(chan struct{})
+	go func() {
+		defer close(unblocked)
+		<-ctx.Done()
+		_ = conn.SetReadDeadline(aLongTimeAgo)
+	}()
+	return ctx, func() {
+		cancel()
+		<-unblocked
+	}, nil
+}
+
+// every calls f every interval until the context is done. The returned channel is closed once it
+// has stopped.
+func every(ctx context.Context, interval time.Duration, f func()) <-chan struct{} {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return stopped
+}
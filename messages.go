@@ -1,9 +1,77 @@
 package wsjtx
 
 import (
+	"fmt"
 	"time"
 )
 
+// Message is implemented by every WSJT-X message type in this package, so that messages can be
+// handled generically, e.g. routed by the WSJT-X instance they belong to.
+type Message interface {
+	// Type returns the message's type number in the WSJT-X protocol.
+	Type() MessageType
+	// ClientId returns the Id of the WSJT-X instance which sent the message or should receive it.
+	ClientId() string
+	// Direction says whether WSJT-X sends this type of message, receives it, or both.
+	Direction() Direction
+	// message seals the interface; only the types in this package are messages.
+	message()
+}
+
+// MessageType is the type number which identifies each message in the WSJT-X protocol.
+type MessageType uint32
+
+var messageTypeNames = map[MessageType]string{
+	HeartbeatType:           "Heartbeat",
+	StatusType:              "Status",
+	DecodeType:              "Decode",
+	ClearType:               "Clear",
+	ReplyType:               "Reply",
+	QsoLoggedType:           "QsoLogged",
+	CloseType:               "Close",
+	ReplayType:              "Replay",
+	HaltTxType:              "HaltTx",
+	FreeTextType:            "FreeText",
+	WSPRDecodeType:          "WSPRDecode",
+	LocationType:            "Location",
+	LoggedAdifType:          "LoggedAdif",
+	HighlightCallsignType:   "HighlightCallsign",
+	SwitchConfigurationType: "SwitchConfiguration",
+	ConfigureType:           "Configure",
+	AnnotationInfoType:      "AnnotationInfo",
+}
+
+func (t MessageType) String() string {
+	if name, ok := messageTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("MessageType(%d)", uint32(t))
+}
+
+// Direction says which way a message type travels, from the point of view of WSJT-X.
+type Direction int
+
+const (
+	// Out messages are sent by WSJT-X to servers.
+	Out Direction = iota
+	// In messages are sent by servers to WSJT-X.
+	In
+	// InOut messages may be sent either way.
+	InOut
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Out:
+		return "Out"
+	case In:
+		return "In"
+	case InOut:
+		return "Out/In"
+	}
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
 /*
 The heartbeat  message shall be  sent on a periodic  basis every
 15   seconds.  This
@@ -14,7 +82,7 @@ after it receives  the initial heartbeat message  from a client.
 
 Out/In.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l110
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type HeartbeatMessage struct {
 	Id        string `json:"id"`
@@ -23,43 +91,56 @@ type HeartbeatMessage struct {
 	Revision  string `json:"revision"`
 }
 
-const heartbeatNum = 0
+const HeartbeatType MessageType = 0
+
+func (HeartbeatMessage) Type() MessageType    { return HeartbeatType }
+func (m HeartbeatMessage) ClientId() string   { return m.Id }
+func (HeartbeatMessage) Direction() Direction { return InOut }
+func (HeartbeatMessage) message()             {}
 
 /*
 WSJT-X  sends this  status message  when various  internal state
 changes to allow the server to  track the relevant state of each
 client without the need for  polling commands.
 
+Older versions of WSJT-X send fewer fields; those which are missing
+are left at their zero values.
+
 Out only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l141
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type StatusMessage struct {
-	Id                   string `json:"id"`
-	DialFrequency        uint64 `json:"dialFrequency"`
-	Mode                 string `json:"mode"`
-	DxCall               string `json:"dxCall"`
-	Report               string `json:"report"`
-	TxMode               string `json:"txMode"`
-	TxEnabled            bool   `json:"txEnabled"`
-	Transmitting         bool   `json:"transmitting"`
-	Decoding             bool   `json:"decoding"`
-	RxDF                 uint32 `json:"rxDeltaFreq"`
-	TxDF                 uint32 `json:"txDeltaFreq"`
-	DeCall               string `json:"deCall"`
-	DeGrid               string `json:"deGrid"`
-	DxGrid               string `json:"dxGrid"`
-	TxWatchdog           bool   `json:"txWatchdog"`
-	SubMode              string `json:"submode"`
-	FastMode             bool   `json:"fastMode"`
-	SpecialOperationMode uint8  `json:"specialMode"`
-	FrequencyTolerance   uint32 `json:"frequencyTolerance"`
-	TRPeriod             uint32 `json:"txRxPeriod"`
-	ConfigurationName    string `json:"configName"`
-	TxMessage            string `json:"txMessage"`
+	Id                   string               `json:"id"`
+	DialFrequency        uint64               `json:"dialFrequency"`
+	Mode                 string               `json:"mode"`
+	DxCall               string               `json:"dxCall"`
+	Report               string               `json:"report"`
+	TxMode               string               `json:"txMode"`
+	TxEnabled            bool                 `json:"txEnabled"`
+	Transmitting         bool                 `json:"transmitting"`
+	Decoding             bool                 `json:"decoding"`
+	RxDF                 uint32               `json:"rxDeltaFreq"`
+	TxDF                 uint32               `json:"txDeltaFreq"`
+	DeCall               string               `json:"deCall"`
+	DeGrid               string               `json:"deGrid"`
+	DxGrid               string               `json:"dxGrid"`
+	TxWatchdog           bool                 `json:"txWatchdog"`
+	SubMode              string               `json:"submode"`
+	FastMode             bool                 `json:"fastMode"`
+	SpecialOperationMode SpecialOperationMode `json:"specialMode"`        // since WSJT-X 2.0
+	FrequencyTolerance   uint32               `json:"frequencyTolerance"` // since WSJT-X 2.2
+	TRPeriod             uint32               `json:"txRxPeriod"`         // since WSJT-X 2.2
+	ConfigurationName    string               `json:"configName"`         // since WSJT-X 2.2
+	TxMessage            string               `json:"txMessage"`          // since WSJT-X 2.3
 }
 
-const statusNum = 1
+const StatusType MessageType = 1
+
+func (StatusMessage) Type() MessageType    { return StatusType }
+func (m StatusMessage) ClientId() string   { return m.Id }
+func (StatusMessage) Direction() Direction { return Out }
+func (StatusMessage) message()             {}
 
 /*
 The decode message is sent when  a new decode is completed, in
@@ -70,7 +151,7 @@ as a one of these messages  with the 'New' field set to false.
 
 Out only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l208
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type DecodeMessage struct {
 	Id               string  `json:"id"`
@@ -85,7 +166,12 @@ type DecodeMessage struct {
 	OffAir           bool    `json:"offAir"`
 }
 
-const decodeNum = 2
+const DecodeType MessageType = 2
+
+func (DecodeMessage) Type() MessageType    { return DecodeType }
+func (m DecodeMessage) ClientId() string   { return m.Id }
+func (DecodeMessage) Direction() Direction { return Out }
+func (DecodeMessage) message()             {}
 
 /*
 This message is  send when all prior "Decode"  messages in the
@@ -100,14 +186,19 @@ The Window  argument  can be  one  of the  following values:
 
 Out/In.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l234
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type ClearMessage struct {
 	Id     string `json:"id"`
 	Window uint8  `json:"window"` // In only
 }
 
-const clearNum = 3
+const ClearType MessageType = 3
+
+func (ClearMessage) Type() MessageType    { return ClearType }
+func (m ClearMessage) ClientId() string   { return m.Id }
+func (ClearMessage) Direction() Direction { return InOut }
+func (ClearMessage) message()             {}
 
 /*
 In order for a server  to provide a useful cooperative service
@@ -120,7 +211,7 @@ the "Band activity" window.
 
 In only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l255
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type ReplyMessage struct {
 	Id               string  `json:"id"`
@@ -134,15 +225,21 @@ type ReplyMessage struct {
 	Modifiers        uint8   `json:"modifiers"`
 }
 
-const replyNum = 4
+const ReplyType MessageType = 4
+
+func (ReplyMessage) Type() MessageType    { return ReplyType }
+func (m ReplyMessage) ClientId() string   { return m.Id }
+func (ReplyMessage) Direction() Direction { return In }
+func (ReplyMessage) message()             {}
 
 /*
 The QSO logged message is sent when the WSJT-X user accepts the "Log  QSO" dialog by clicking
-the "OK" button.
+the "OK" button. Older versions of WSJT-X send fewer fields; those which are missing are left at
+their zero values.
 
 Out only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l295
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type QsoLoggedMessage struct {
 	Id                  string    `json:"id"`
@@ -160,12 +257,17 @@ type QsoLoggedMessage struct {
 	OperatorCall        string    `json:"operatorCall"`
 	MyCall              string    `json:"myCall"`
 	MyGrid              string    `json:"myGrid"`
-	ExchangeSent        string    `json:"exchangeSent"`
-	ExchangeReceived    string    `json:"exchangeReceived"`
-	ADIFPropagationMode string    `json:"propagationMode"`
+	ExchangeSent        string    `json:"exchangeSent"`     // since WSJT-X 2.0
+	ExchangeReceived    string    `json:"exchangeReceived"` // since WSJT-X 2.0
+	ADIFPropagationMode string    `json:"propagationMode"`  // since WSJT-X 2.5
 }
 
-const qsoLoggedNum = 5
+const QsoLoggedType MessageType = 5
+
+func (QsoLoggedMessage) Type() MessageType    { return QsoLoggedType }
+func (m QsoLoggedMessage) ClientId() string   { return m.Id }
+func (QsoLoggedMessage) Direction() Direction { return Out }
+func (QsoLoggedMessage) message()             {}
 
 /*
 Close is  sent by  a client immediately  prior to  it shutting
@@ -173,13 +275,18 @@ down gracefully.
 
 Out/In.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l320
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type CloseMessage struct {
 	Id string `json:"id"`
 }
 
-const closeNum = 6
+const CloseType MessageType = 6
+
+func (CloseMessage) Type() MessageType    { return CloseType }
+func (m CloseMessage) ClientId() string   { return m.Id }
+func (CloseMessage) Direction() Direction { return InOut }
+func (CloseMessage) message()             {}
 
 /*
 When a server starts it may  be useful for it to determine the
@@ -190,13 +297,18 @@ activity"  window.
 
 In only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l328
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type ReplayMessage struct {
 	Id string `json:"id"`
 }
 
-const replayNum = 7
+const ReplayType MessageType = 7
+
+func (ReplayMessage) Type() MessageType    { return ReplayType }
+func (m ReplayMessage) ClientId() string   { return m.Id }
+func (ReplayMessage) Direction() Direction { return In }
+func (ReplayMessage) message()             {}
 
 /*
 The server may stop a client from transmitting messages either
@@ -205,14 +317,19 @@ using this message.
 
 In only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l343
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type HaltTxMessage struct {
 	Id         string `json:"id"`
 	AutoTxOnly bool   `json:"autoTxOnly"`
 }
 
-const haltTxNum = 8
+const HaltTxType MessageType = 8
+
+func (HaltTxMessage) Type() MessageType    { return HaltTxType }
+func (m HaltTxMessage) ClientId() string   { return m.Id }
+func (HaltTxMessage) Direction() Direction { return In }
+func (HaltTxMessage) message()             {}
 
 /*
 This message  allows the server  to set the current  free text
@@ -226,7 +343,7 @@ is current.
 
 In only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l352
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type FreeTextMessage struct {
 	Id   string `json:"id"`
@@ -234,7 +351,12 @@ type FreeTextMessage struct {
 	Send bool   `json:"send"`
 }
 
-const freeTextNum = 9
+const FreeTextType MessageType = 9
+
+func (FreeTextMessage) Type() MessageType    { return FreeTextType }
+func (m FreeTextMessage) ClientId() string   { return m.Id }
+func (FreeTextMessage) Direction() Direction { return In }
+func (FreeTextMessage) message()             {}
 
 /*
 The decode message is sent when  a new decode is completed, in
@@ -242,7 +364,7 @@ this case the 'New' field is true.
 
 Out only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l383
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type WSPRDecodeMessage struct {
 	Id        string  `json:"id"`
@@ -258,7 +380,12 @@ type WSPRDecodeMessage struct {
 	OffAir    bool    `json:"offAir"`
 }
 
-const wsprDecodeNum = 10
+const WSPRDecodeType MessageType = 10
+
+func (WSPRDecodeMessage) Type() MessageType    { return WSPRDecodeType }
+func (m WSPRDecodeMessage) ClientId() string   { return m.Id }
+func (WSPRDecodeMessage) Direction() Direction { return Out }
+func (WSPRDecodeMessage) message()             {}
 
 /*
 This  message allows  the server  to set  the current  current
@@ -269,14 +396,19 @@ application  settings.
 
 In only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l406
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type LocationMessage struct {
 	Id       string `json:"id"`
 	Location string `json:"location"`
 }
 
-const locationNum = 11
+const LocationType MessageType = 11
+
+func (LocationMessage) Type() MessageType    { return LocationType }
+func (m LocationMessage) ClientId() string   { return m.Id }
+func (LocationMessage) Direction() Direction { return In }
+func (LocationMessage) message()             {}
 
 /*
 The  logged ADIF  message is  sent to  the server(s)  when the
@@ -285,14 +417,19 @@ button.
 
 Out only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l423
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type LoggedAdifMessage struct {
 	Id   string `json:"id"`
 	Adif string `json:"adif"`
 }
 
-const loggedAdifNum = 12
+const LoggedAdifType MessageType = 12
+
+func (LoggedAdifMessage) Type() MessageType    { return LoggedAdifType }
+func (m LoggedAdifMessage) ClientId() string   { return m.Id }
+func (LoggedAdifMessage) Direction() Direction { return Out }
+func (LoggedAdifMessage) message()             {}
 
 /*
 The server  may send  this message at  any time.   The message
@@ -304,7 +441,7 @@ either or both  of the background and  foreground fields.
 
 In only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l444
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type HighlightCallsignMessage struct {
 	Id              string `json:"id"`
@@ -319,7 +456,12 @@ type HighlightCallsignMessage struct {
 	Reset bool `json:"reset"`
 }
 
-const highlightCallsignNum = 13
+const HighlightCallsignType MessageType = 13
+
+func (HighlightCallsignMessage) Type() MessageType    { return HighlightCallsignType }
+func (m HighlightCallsignMessage) ClientId() string   { return m.Id }
+func (HighlightCallsignMessage) Direction() Direction { return In }
+func (HighlightCallsignMessage) message()             {}
 
 /*
 The server  may send  this message at  any time.   The message
@@ -328,14 +470,19 @@ configuration must exist.
 
 In only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l445
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type SwitchConfigurationMessage struct {
 	Id                string `json:"id"`
 	ConfigurationName string `json:"configurationName"`
 }
 
-const switchConfigurationNum = 14
+const SwitchConfigurationType MessageType = 14
+
+func (SwitchConfigurationMessage) Type() MessageType    { return SwitchConfigurationType }
+func (m SwitchConfigurationMessage) ClientId() string   { return m.Id }
+func (SwitchConfigurationMessage) Direction() Direction { return In }
+func (SwitchConfigurationMessage) message()             {}
 
 /*
 The server  may send  this message at  any time.   The message
@@ -347,7 +494,7 @@ silently ignored.
 
 In only.
 
-https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.5.2/tree/Network/NetworkMessage.hpp#l479
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
 */
 type ConfigureMessage struct {
 	Id                 string `json:"id"`
@@ -362,4 +509,52 @@ type ConfigureMessage struct {
 	GenerateMessages   bool   `json:"generateMessages"`
 }
 
-const configureNum = 15
+const ConfigureType MessageType = 15
+
+func (ConfigureMessage) Type() MessageType    { return ConfigureType }
+func (m ConfigureMessage) ClientId() string   { return m.Id }
+func (ConfigureMessage) Direction() Direction { return In }
+func (ConfigureMessage) message()             {}
+
+/*
+The server  may send  this message at  any time.  The message
+provides annotation information for a DX call; currently a sort
+order, which WSJT-X uses to order the callers waiting in the Fox
+mode queue.  If Sort Order Provided is false the Sort Order field
+is ignored.
+
+In only. Since WSJT-X 2.7.
+
+https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp
+*/
+type AnnotationInfoMessage struct {
+	Id                string `json:"id"`
+	DxCall            string `json:"dxCall"`
+	SortOrderProvided bool   `json:"sortOrderProvided"`
+	SortOrder         uint32 `json:"sortOrder"`
+}
+
+const AnnotationInfoType MessageType = 16
+
+func (AnnotationInfoMessage) Type() MessageType    { return AnnotationInfoType }
+func (m AnnotationInfoMessage) ClientId() string   { return m.Id }
+func (AnnotationInfoMessage) Direction() Direction { return In }
+func (AnnotationInfoMessage) message()             {}
+
+/*
+UnknownMessage holds a message of a type this library doesn't know, e.g. one added in a newer
+version of WSJT-X. It's only produced by Lenient parsing. Payload holds every byte after the
+message type, starting with the Id, so the message can be passed on unchanged.
+*/
+type UnknownMessage struct {
+	Id         string      `json:"id"`
+	TypeNumber MessageType `json:"type"`
+	Payload    []byte      `json:"payload"`
+}
+
+func (m UnknownMessage) Type() MessageType { return m.TypeNumber }
+func (m UnknownMessage) ClientId() string  { return m.Id }
+
+// Direction can't be known for an unknown message type, so it's assumed to go either way.
+func (UnknownMessage) Direction() Direction { return InOut }
+func (UnknownMessage) message()             {}
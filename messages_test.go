@@ -0,0 +1,47 @@
+package wsjtx
+
+import "testing"
+
+func TestMessageInterface(t *testing.T) {
+	tests := []struct {
+		message   Message
+		wantType  MessageType
+		wantName  string
+		wantDir   Direction
+		wantRawNo uint32
+	}{
+		{HeartbeatMessage{Id: "a"}, HeartbeatType, "Heartbeat", InOut, 0},
+		{StatusMessage{Id: "a"}, StatusType, "Status", Out, 1},
+		{DecodeMessage{Id: "a"}, DecodeType, "Decode", Out, 2},
+		{ClearMessage{Id: "a"}, ClearType, "Clear", InOut, 3},
+		{ReplyMessage{Id: "a"}, ReplyType, "Reply", In, 4},
+		{QsoLoggedMessage{Id: "a"}, QsoLoggedType, "QsoLogged", Out, 5},
+		{CloseMessage{Id: "a"}, CloseType, "Close", InOut, 6},
+		{ReplayMessage{Id: "a"}, ReplayType, "Replay", In, 7},
+		{HaltTxMessage{Id: "a"}, HaltTxType, "HaltTx", In, 8},
+		{FreeTextMessage{Id: "a"}, FreeTextType, "FreeText", In, 9},
+		{WSPRDecodeMessage{Id: "a"}, WSPRDecodeType, "WSPRDecode", Out, 10},
+		{LocationMessage{Id: "a"}, LocationType, "Location", In, 11},
+		{LoggedAdifMessage{Id: "a"}, LoggedAdifType, "LoggedAdif", Out, 12},
+		{HighlightCallsignMessage{Id: "a"}, HighlightCallsignType, "HighlightCallsign", In, 13},
+		{SwitchConfigurationMessage{Id: "a"}, SwitchConfigurationType, "SwitchConfiguration", In, 14},
+		{ConfigureMessage{Id: "a"}, ConfigureType, "Configure", In, 15},
+		{AnnotationInfoMessage{Id: "a"}, AnnotationInfoType, "AnnotationInfo", In, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantName, func(t *testing.T) {
+			if got := tt.message.Type(); got != tt.wantType || uint32(got) != tt.wantRawNo {
+				t.Errorf("Type() = %d, want %d", got, tt.wantRawNo)
+			}
+			if got := tt.message.Type().String(); got != tt.wantName {
+				t.Errorf("Type().String() = %s, want %s", got, tt.wantName)
+			}
+			if got := tt.message.Direction(); got != tt.wantDir {
+				t.Errorf("Direction() = %v, want %v", got, tt.wantDir)
+			}
+			if got := tt.message.ClientId(); got != "a" {
+				t.Errorf("ClientId() = %s, want a", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package wsjtx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// DefaultMulticastGroup is the group used when MulticastOptions doesn't name one. It is in the
+// administratively scoped range 239.255.0.0/16, which WSJT-X recommends over 224.0.0.1.
+var DefaultMulticastGroup = net.IPv4(239, 255, 0, 1)
+
+// MulticastOptions configures talking to WSJT-X over a multicast group, matching WSJT-X's "UDP
+// Server" address, "Outgoing interfaces" and "Multicast TTL" settings. The zero value means the
+// default group and port, the system's choice of interface and a TTL of 1.
+type MulticastOptions struct {
+	// Group is the IPv4 or IPv6 multicast group, e.g. 239.255.0.1 or ff02::1. IPv6 link-local
+	// groups need an Interface too.
+	Group net.IP
+	// Port defaults to 2237, the port WSJT-X sends to by default.
+	Port uint
+	// Interface is the name of the network interface to join the group on and send multicast from,
+	// e.g. "eth0". The system chooses if it's empty.
+	Interface string
+	// TTL is how many hops outgoing multicast datagrams may travel. Zero means 1, which keeps them
+	// on the local network, as does WSJT-X by default.
+	TTL int
+	// NoLoopback stops outgoing multicast datagrams from being delivered back to this host, where
+	// WSJT-X and its servers usually run.
+	NoLoopback bool
+}
+
+// resolve fills in the defaults and looks up the interface, which is nil if none was named.
+func (o MulticastOptions) resolve() (MulticastOptions, *net.Interface, error) {
+	if o.Group == nil {
+		o.Group = DefaultMulticastGroup
+	}
+	if !o.Group.IsMulticast() {
+		return o, nil, fmt.Errorf("%v isn't a multicast group", o.Group)
+	}
+	if o.Port == 0 {
+		o.Port = wsjtxPort
+	}
+	if o.TTL == 0 {
+		o.TTL = 1
+	}
+	if o.TTL < 0 || o.TTL > 255 {
+		return o, nil, fmt.Errorf("multicast TTL %d isn't between 1 and 255", o.TTL)
+	}
+	if o.Interface == "" {
+		return o, nil, nil
+	}
+	ifi, err := net.InterfaceByName(o.Interface)
+	if err != nil {
+		return o, nil, fmt.Errorf("multicast interface %q: %w", o.Interface, err)
+	}
+	return o, ifi, nil
+}
+
+func (o MulticastOptions) ipv6() bool {
+	return o.Group.To4() == nil
+}
+
+func (o MulticastOptions) network() string {
+	if o.ipv6() {
+		return "udp6"
+	}
+	return "udp4"
+}
+
+func (o MulticastOptions) addr(ifi *net.Interface) *net.UDPAddr {
+	addr := &net.UDPAddr{IP: o.Group, Port: int(o.Port)}
+	if ifi != nil && o.ipv6() {
+		addr.Zone = ifi.Name
+	}
+	return addr
+}
+
+// MakeMulticastServer creates a server which joins a multicast group to hear from WSJT-X, as
+// configured by the options.
+//
+// Deprecated: use NewServer with WithMulticast.
+func MakeMulticastServer(opts MulticastOptions) (Server, error) {
+	return makeServer(WithMulticast(opts))
+}
+
+// listenMulticast opens a socket which has joined the multicast group.
+func listenMulticast(opts MulticastOptions) (*net.UDPConn, error) {
+	opts, ifi, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenMulticastUDP(opts.network(), ifi, opts.addr(ifi))
+	if err != nil {
+		return nil, err
+	}
+	if err := applyMulticastOptions(conn, opts, ifi); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// MakeMulticastClient creates a client which sends to a multicast group, as configured by the
+// options, like WSJT-X does when its UDP Server setting is a multicast address.
+func MakeMulticastClient(id string, opts MulticastOptions) (*Client, error) {
+	opts, ifi, err := opts.resolve()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP(opts.network(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyMulticastOptions(conn, opts, ifi); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return newClient(id, opts.addr(ifi), conn)
+}
+
+// multicastSockopts are the socket options for sending multicast, in the form the setsockopt
+// calls in the sockopt files want.
+type multicastSockopts struct {
+	ipv6     bool
+	ttl      int
+	loopback bool
+	// ifIndex is the outgoing interface's index, or 0 to leave the system's choice. Some systems
+	// choose an IPv4 interface by its address, ifAddr, instead.
+	ifIndex int
+	ifAddr  [4]byte
+}
+
+// applyMulticastOptions sets the TTL, loopback and outgoing interface of the socket.
+func applyMulticastOptions(conn *net.UDPConn, opts MulticastOptions, ifi *net.Interface) error {
+	sockopts := multicastSockopts{ipv6: opts.ipv6(), ttl: opts.TTL, loopback: !opts.NoLoopback}
+	if ifi != nil {
+		sockopts.ifIndex = ifi.Index
+		if !sockopts.ipv6 {
+			ip, err := interfaceIPv4(ifi)
+			if err != nil {
+				return err
+			}
+			copy(sockopts.ifAddr[:], ip)
+		}
+	}
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockoptErr error
+	err = raw.Control(func(fd uintptr) {
+		sockoptErr = setMulticastSockopts(fd, sockopts)
+	})
+	if err != nil {
+		return err
+	}
+	if sockoptErr != nil {
+		return fmt.Errorf("setting multicast options: %w", sockoptErr)
+	}
+	return nil
+}
+
+// interfaceIPv4 returns the first IPv4 address of the interface.
+func interfaceIPv4(ifi *net.Interface) (net.IP, error) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.To4(), nil
+		}
+	}
+	return nil, errors.New("multicast interface " + ifi.Name + " has no IPv4 address")
+}
@@ -0,0 +1,66 @@
+package wsjtx
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMulticastOptionsResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    MulticastOptions
+		want    MulticastOptions
+		network string
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			opts:    MulticastOptions{},
+			want:    MulticastOptions{Group: DefaultMulticastGroup, Port: 2237, TTL: 1},
+			network: "udp4",
+		},
+		{
+			name: "IPv6",
+			opts: MulticastOptions{Group: net.ParseIP("ff02::1"), Port: 2238, TTL: 2, NoLoopback: true},
+			want: MulticastOptions{Group: net.ParseIP("ff02::1"), Port: 2238, TTL: 2,
+				NoLoopback: true},
+			network: "udp6",
+		},
+		{
+			name:    "not multicast",
+			opts:    MulticastOptions{Group: net.ParseIP("127.0.0.1")},
+			wantErr: true,
+		},
+		{
+			name:    "TTL too big",
+			opts:    MulticastOptions{TTL: 256},
+			wantErr: true,
+		},
+		{
+			name:    "no such interface",
+			opts:    MulticastOptions{Interface: "no-such-interface0"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ifi, err := tt.opts.resolve()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !got.Group.Equal(tt.want.Group) || got.Port != tt.want.Port || got.TTL != tt.want.TTL ||
+				got.NoLoopback != tt.want.NoLoopback {
+				t.Errorf("resolve() = %+v, want %+v", got, tt.want)
+			}
+			if ifi != nil {
+				t.Errorf("resolve() interface = %v, want nil", ifi)
+			}
+			if got.network() != tt.network {
+				t.Errorf("network() = %s, want %s", got.network(), tt.network)
+			}
+		})
+	}
+}
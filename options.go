@@ -0,0 +1,288 @@
+package wsjtx
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"runtime"
+	"time"
+)
+
+// ReadTimeoutError is reported, without stopping the server, when nothing has been heard from
+// WSJT-X for the read timeout set with WithReadTimeout.
+var ReadTimeoutError = errors.New("nothing heard from wsjtx")
+
+// Option configures a Server made by NewServer.
+type Option func(*serverOptions) error
+
+// serverOptions collects the options. Those which only matter while opening the socket stay here;
+// the rest are kept by the Server in its serverConfig.
+type serverOptions struct {
+	addr         net.IP
+	port         uint
+	portSet      bool
+	multicast    *MulticastOptions
+	socketBuffer int
+	logger       *log.Logger
+	config       serverConfig
+}
+
+// serverConfig is how a Server behaves once its socket is open.
+type serverConfig struct {
+	datagramSize  int
+	readTimeout   time.Duration
+	parseMode     ParseMode
+	maxSchema     uint32
+	messageBuffer int
+	errorBuffer   int
+	now           func() time.Time
+	keepAlive
+}
+
+// keepAlive configures the heartbeats the server sends and how it checks that clients are alive.
+type keepAlive struct {
+	heartbeatInterval time.Duration
+	version           string
+	revision          string
+	livenessInterval  time.Duration
+	staleAfter        int
+	disconnectAfter   int
+}
+
+func defaultServerConfig() serverConfig {
+	return serverConfig{
+		datagramSize:  MaxDatagramSize,
+		parseMode:     Lenient,
+		maxSchema:     maxSchema,
+		messageBuffer: 5,
+		errorBuffer:   5,
+		now:           time.Now,
+	}
+}
+
+// WithAddress sets the address to listen for WSJT-X on, which may be a multicast group. The
+// default is the multicast group 224.0.0.1, or localhost on Windows.
+func WithAddress(ip net.IP) Option {
+	return func(o *serverOptions) error {
+		if ip == nil {
+			return errors.New("wsjtx server address is nil")
+		}
+		o.addr = ip
+		return nil
+	}
+}
+
+// WithPort sets the port to listen for WSJT-X on. The default is 2237, the port WSJT-X sends to by
+// default; 0 makes the OS assign one.
+func WithPort(port uint) Option {
+	return func(o *serverOptions) error {
+		if port > 65535 {
+			return fmt.Errorf("wsjtx server port %d is out of range", port)
+		}
+		o.port, o.portSet = port, true
+		return nil
+	}
+}
+
+// WithMulticast joins a multicast group to hear from WSJT-X, with control over the interface, TTL
+// and loopback. It can't be combined with WithAddress or WithPort.
+func WithMulticast(opts MulticastOptions) Option {
+	return func(o *serverOptions) error {
+		o.multicast = &opts
+		return nil
+	}
+}
+
+// WithReceiveBufferSize sets the size in bytes of the socket's receive buffer in the OS, which
+// holds datagrams until the server reads them. The OS's default is used if this isn't given.
+func WithReceiveBufferSize(size int) Option {
+	return func(o *serverOptions) error {
+		if size <= 0 {
+			return fmt.Errorf("wsjtx receive buffer size %d isn't positive", size)
+		}
+		o.socketBuffer = size
+		return nil
+	}
+}
+
+// WithDatagramSize sets the size in bytes of the largest datagram the server accepts. Larger ones
+// are dropped and reported as a DatagramTruncatedError. The default is MaxDatagramSize, which
+// means each buffer the server reads into, one per datagram being handled, takes 64 KiB; a smaller
+// size saves memory if WSJT-X's messages are known to be short.
+func WithDatagramSize(size int) Option {
+	return func(o *serverOptions) error {
+		if size <= 0 || size > MaxDatagramSize {
+			return fmt.Errorf("wsjtx datagram size %d isn't between 1 and %d", size, MaxDatagramSize)
+		}
+		o.config.datagramSize = size
+		return nil
+	}
+}
+
+// WithReadTimeout makes Listen report a ReadTimeoutError whenever nothing has been heard from
+// WSJT-X for the given time, e.g. to notice that it has stopped. Listen carries on listening
+// afterward. Zero, the default, waits forever.
+func WithReadTimeout(d time.Duration) Option {
+	return func(o *serverOptions) error {
+		if d < 0 {
+			return fmt.Errorf("wsjtx read timeout %v is negative", d)
+		}
+		o.config.readTimeout = d
+		return nil
+	}
+}
+
+// WithLogger logs every error the server reports, as OnError handlers see them.
+func WithLogger(logger *log.Logger) Option {
+	return func(o *serverOptions) error {
+		o.logger = logger
+		return nil
+	}
+}
+
+// WithMaxSchema sets the highest schema the server negotiates with WSJT-X and advertises in its
+// heartbeats. The default, 3, is the highest this library speaks.
+func WithMaxSchema(schema uint32) Option {
+	return func(o *serverOptions) error {
+		if schema < minSchema || schema > maxSchema {
+			return fmt.Errorf("schema %d isn't between %d and %d", schema, minSchema, maxSchema)
+		}
+		o.config.maxSchema = schema
+		return nil
+	}
+}
+
+// WithParseMode chooses how strictly messages from WSJT-X are parsed, as SetParseMode does. The
+// default is Lenient.
+func WithParseMode(mode ParseMode) Option {
+	return func(o *serverOptions) error {
+		if mode != Strict && mode != Lenient {
+			return fmt.Errorf("unknown parse mode %d", mode)
+		}
+		o.config.parseMode = mode
+		return nil
+	}
+}
+
+// WithChannelSizes sets the buffer sizes of the message and error channels made by Serve. Both
+// default to 5.
+func WithChannelSizes(messages, errs int) Option {
+	return func(o *serverOptions) error {
+		if messages < 0 || errs < 0 {
+			return fmt.Errorf("wsjtx channel sizes %d and %d can't be negative", messages, errs)
+		}
+		o.config.messageBuffer, o.config.errorBuffer = messages, errs
+		return nil
+	}
+}
+
+// WithClock sets where the server gets the time, for when clients were last seen and for checking
+// their liveness. The default is time.Now; tests can use a fake clock.
+func WithClock(now func() time.Time) Option {
+	return func(o *serverOptions) error {
+		if now == nil {
+			return errors.New("wsjtx server clock is nil")
+		}
+		o.config.now = now
+		return nil
+	}
+}
+
+// WithHeartbeatInterval makes the server send heartbeats while listening, as SetHeartbeatInterval
+// does.
+func WithHeartbeatInterval(d time.Duration) Option {
+	return func(o *serverOptions) error {
+		if d < 0 {
+			return fmt.Errorf("wsjtx heartbeat interval %v is negative", d)
+		}
+		o.config.heartbeatInterval = d
+		return nil
+	}
+}
+
+// WithVersion sets the version and revision advertised in the server's heartbeats, as SetVersion
+// does.
+func WithVersion(version, revision string) Option {
+	return func(o *serverOptions) error {
+		o.config.version, o.config.revision = version, revision
+		return nil
+	}
+}
+
+// WithLiveness makes the server check that clients are still alive while listening, as
+// SetLiveness does.
+func WithLiveness(interval time.Duration, staleAfter, disconnectAfter int) Option {
+	return func(o *serverOptions) error {
+		if interval < 0 || staleAfter < 0 || disconnectAfter < 0 {
+			return errors.New("wsjtx liveness settings can't be negative")
+		}
+		o.config.livenessInterval = interval
+		o.config.staleAfter, o.config.disconnectAfter = staleAfter, disconnectAfter
+		return nil
+	}
+}
+
+// NewServer creates a server configured by the options. With none, it listens on the same address
+// and port as MakeServer.
+func NewServer(opts ...Option) (*Server, error) {
+	o := serverOptions{port: wsjtxPort, config: defaultServerConfig()}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := o.listen()
+	if err != nil {
+		return nil, err
+	}
+	if o.socketBuffer > 0 {
+		if err := conn.SetReadBuffer(o.socketBuffer); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	s := newServer(conn, o.config)
+	if o.logger != nil {
+		s.OnError(func(err error) { o.logger.Printf("wsjtx: %v", err) })
+	}
+	return &s, nil
+}
+
+// listen opens the socket the options describe.
+func (o serverOptions) listen() (*net.UDPConn, error) {
+	if o.multicast != nil {
+		if o.addr != nil || o.portSet {
+			return nil, errors.New("wsjtx server can't have both an address and multicast options")
+		}
+		return listenMulticast(*o.multicast)
+	}
+	ip := o.addr
+	if ip == nil {
+		ip = defaultServerAddr()
+	}
+	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%v:%d", ip, o.port))
+	if err != nil {
+		return nil, err
+	}
+	var conn *net.UDPConn
+	if ip.IsMulticast() {
+		conn, err = net.ListenMulticastUDP(addr.Network(), nil, addr)
+	} else {
+		conn, err = net.ListenUDP(addr.Network(), addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if conn == nil {
+		return nil, errors.New("wsjtx udp connection not opened")
+	}
+	return conn, nil
+}
+
+func defaultServerAddr() net.IP {
+	if runtime.GOOS == "windows" {
+		return net.ParseIP(localhostAddr)
+	}
+	return net.ParseIP(multicastAddr)
+}
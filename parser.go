@@ -5,325 +5,572 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"reflect"
-	"time"
 
-	"github.com/leemcloughlin/jdn"
+	"github.com/mazznoer/csscolorparser"
 )
 
+// parser reads one datagram. The first error it meets is kept in err and every later read is a
+// no-op, so the parseX functions can read field after field and check once at the end.
 type parser struct {
-	buffer []byte
-	length int
-	cursor int
+	buffer   []byte
+	length   int
+	cursor   int
+	mode     ParseMode
+	schema   uint32
+	trailing []byte
+	message  string
+	err      error
+	strings  *stringTable
+	zones    *zoneTable
 }
 
 var ParseError = errors.New("parse error")
 var notEnoughBytes = fmt.Errorf("%w: fewer bytes than expected, maybe an older version of WSJTX", ParseError)
 
+// FieldError reports which field of a message couldn't be parsed or encoded, and the byte offset
+// in the datagram where that field starts. It wraps ParseError or EncodeError respectively.
+type FieldError struct {
+	Message string
+	Field   string
+	Offset  int
+	Err     error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%v (%s.%s at offset %d)", e.Err, e.Message, e.Field, e.Offset)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ParseMode selects how the parser treats data it doesn't understand.
+type ParseMode int
+
+const (
+	// Lenient parsing returns messages of unknown types as UnknownMessage, and ignores bytes after
+	// the last field known for a message type, so that newer versions of WSJT-X can be understood.
+	Lenient ParseMode = iota
+	// Strict parsing reports unknown message types and leftover bytes as a ParseError.
+	Strict
+)
+
+// Parse parses one WSJT-X datagram. In Lenient mode, any bytes after the last field known for the
+// message's type are returned as trailing; in Strict mode they cause a ParseError instead.
+func Parse(datagram []byte, mode ParseMode) (msg Message, trailing []byte, err error) {
+	p := newParser(datagram, len(datagram), mode)
+	msg, err = p.parse()
+	return msg, p.trailing, err
+}
+
+// Parser parses datagrams like Parse, but is meant to be kept and reused, e.g. for a stream of
+// datagrams from the same WSJT-X instances. It remembers the short strings it has seen recently,
+// such as Ids, modes, callsigns and grids, so that parsing the same ones again doesn't allocate,
+// and the time zones it has loaded. A Parser isn't safe for concurrent use.
+type Parser struct {
+	mode    ParseMode
+	strings stringTable
+	zones   zoneTable
+	schema  uint32
+}
+
+// NewParser makes a Parser with the given mode.
+func NewParser(mode ParseMode) *Parser {
+	return &Parser{mode: mode}
+}
+
+// Parse parses one WSJT-X datagram, as the Parse function does. Neither the message nor trailing
+// refers to the datagram, which may be reused once Parse returns; trailing is a copy.
+func (pp *Parser) Parse(datagram []byte) (msg Message, trailing []byte, err error) {
+	p := newParser(datagram, len(datagram), pp.mode)
+	p.strings = &pp.strings
+	p.zones = &pp.zones
+	msg, err = p.parse()
+	pp.schema = p.schema
+	return msg, p.trailing, err
+}
+
 // Parse messages following the interface laid out in
-// https://sourceforge.net/p/wsjt/wsjtx/ci/master/tree/Network/NetworkMessage.hpp. This only parses
-// "Out" or "In/Out" message types and does not include "In" types because they will never be
-// received by WSJT-X.
-func parseMessage(buffer []byte, length int) (interface{}, error) {
-	p := parser{buffer: buffer, length: length, cursor: 0}
-	m, err := p.parseUint32()
-	if err != nil {
-		return nil, ParseError
+// https://sourceforge.net/p/wsjt/wsjtx/ci/wsjtx-2.7.0/tree/Network/NetworkMessage.hpp. Every
+// message type is parsed, including "In" types which only servers send, so that proxies and
+// sniffers can understand both sides of the conversation.
+func parseMessage(buffer []byte, length int) (Message, error) {
+	p := newParser(buffer, length, Strict)
+	return p.parse()
+}
+
+// newParser makes a parser for the first length bytes of buffer. A length outside the buffer is
+// clamped to it.
+func newParser(buffer []byte, length int, mode ParseMode) parser {
+	if length < 0 || length > len(buffer) {
+		length = len(buffer)
+	}
+	return parser{buffer: buffer, length: length, mode: mode, message: "Header"}
+}
+
+// parse parses the whole datagram, leaving the schema number from its header in p.schema.
+func (p *parser) parse() (Message, error) {
+	m := p.parseUint32("Magic")
+	if p.err != nil {
+		return nil, p.err
 	}
 	if m != magic {
 		return nil, fmt.Errorf("%w: packet is not speaking the WSJT-X protocol", ParseError)
 	}
-	sch, err := p.parseUint32()
-	if err != nil {
-		return nil, ParseError
+	sch := p.parseUint32("Schema")
+	if p.err != nil {
+		return nil, p.err
 	}
-	if sch != schema {
+	if sch < minSchema || sch > maxSchema {
 		return nil, fmt.Errorf("%w: got a schema version I wasn't expecting: %d", ParseError, sch)
 	}
+	p.schema = sch
+
+	messageType := MessageType(p.parseUint32("Type"))
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.message = messageType.String()
 
-	messageType, _ := p.parseUint32()
+	var message Message
 	switch messageType {
-	case heartbeatNum:
-		heartbeat, err := p.parseHeartbeat()
-		if err != nil {
-			return heartbeat, err
-		}
-		err = p.checkParse(heartbeat)
-		return heartbeat, err
-	case statusNum:
-		status, err := p.parseStatus()
-		if err != nil {
-			return status, err
-		}
-		err = p.checkParse(status)
-		return status, err
-	case decodeNum:
-		decode, err := p.parseDecode()
-		if err != nil {
-			return decode, err
-		}
-		err = p.checkParse(decode)
-		return decode, err
-	case clearNum:
-		clear, err := p.parseClear()
-		if err != nil {
-			return clear, err
-		}
-		err = p.checkParse(clear)
-		return clear, err
-	case qsoLoggedNum:
-		qsoLogged, err := p.parseQsoLogged()
-		if err != nil {
-			return qsoLogged, err
-		}
-		err = p.checkParse(qsoLogged)
-		return qsoLogged, err
-	case closeNum:
-		closeMsg, err := p.parseClose()
-		if err != nil {
-			return closeMsg, err
-		}
-		err = p.checkParse(closeMsg)
-		return closeMsg, err
-	case wsprDecodeNum:
-		wspr, err := p.parseWsprDecode()
-		if err != nil {
-			return wspr, err
-		}
-		err = p.checkParse(wspr)
-		return wspr, err
-	case loggedAdifNum:
-		loggedAdif, err := p.parseLoggedAdif()
-		if err != nil {
-			return loggedAdif, err
+	case HeartbeatType:
+		message = p.parseHeartbeat()
+	case StatusType:
+		message = p.parseStatus()
+	case DecodeType:
+		message = p.parseDecode()
+	case ClearType:
+		message = p.parseClear()
+	case ReplyType:
+		message = p.parseReply()
+	case QsoLoggedType:
+		message = p.parseQsoLogged()
+	case CloseType:
+		message = p.parseClose()
+	case ReplayType:
+		message = p.parseReplay()
+	case HaltTxType:
+		message = p.parseHaltTx()
+	case FreeTextType:
+		message = p.parseFreeText()
+	case WSPRDecodeType:
+		message = p.parseWsprDecode()
+	case LocationType:
+		message = p.parseLocation()
+	case LoggedAdifType:
+		message = p.parseLoggedAdif()
+	case HighlightCallsignType:
+		message = p.parseHighlightCallsign()
+	case SwitchConfigurationType:
+		message = p.parseSwitchConfiguration()
+	case ConfigureType:
+		message = p.parseConfigure()
+	case AnnotationInfoType:
+		message = p.parseAnnotationInfo()
+	default:
+		if p.mode == Lenient {
+			return p.parseUnknown(messageType), nil
 		}
-		err = p.checkParse(loggedAdif)
-		return loggedAdif, err
+		return nil, fmt.Errorf("%w: unknown message type %d", ParseError, messageType)
 	}
-	return nil, fmt.Errorf("%w: unknown message type %d", ParseError, messageType)
+	if p.err != nil {
+		return message, p.err
+	}
+	return message, p.checkParse(message)
+}
+
+// exhausted reports whether every byte of the message has been parsed. Fields which were added to
+// the protocol over time are only parsed when there are bytes left, so that messages from older
+// versions of WSJT-X, which stop short, aren't rejected.
+func (p *parser) exhausted() bool {
+	return p.err != nil || p.cursor >= p.length
 }
 
-// Quick sanity check that we parsed all of the message bytes
-func (p *parser) checkParse(message interface{}) error {
+// Quick sanity check that we parsed all of the message bytes. Leniently, leftover bytes are kept
+// as trailing instead.
+func (p *parser) checkParse(message Message) error {
+	if p.mode == Lenient && p.cursor < p.length {
+		p.trailing = append([]byte(nil), p.buffer[p.cursor:p.length]...)
+		return nil
+	}
 	if p.cursor != p.length {
 		return fmt.Errorf("%w %s: there were %d bytes left over",
-			ParseError, reflect.TypeOf(message).Name(), p.length-p.cursor)
+			ParseError, message.Type(), p.length-p.cursor)
 	}
 	return nil
 }
 
-func (p *parser) parseHeartbeat() (HeartbeatMessage, error) {
-	var err error
+func (p *parser) parseHeartbeat() HeartbeatMessage {
 	heartbeatMessage := HeartbeatMessage{}
-	heartbeatMessage.Id, err = p.parseUtf8()
-	heartbeatMessage.MaxSchema, err = p.parseUint32()
-	heartbeatMessage.Version, err = p.parseUtf8()
-	heartbeatMessage.Revision, err = p.parseUtf8()
-	return heartbeatMessage, err
+	heartbeatMessage.Id = p.parseUtf8("Id")
+	heartbeatMessage.MaxSchema = p.parseUint32("MaxSchema")
+	heartbeatMessage.Version = p.parseUtf8("Version")
+	heartbeatMessage.Revision = p.parseUtf8("Revision")
+	return heartbeatMessage
 }
 
-func (p *parser) parseStatus() (StatusMessage, error) {
-	var err error
+func (p *parser) parseStatus() StatusMessage {
 	statusMessage := StatusMessage{}
-	statusMessage.Id, err = p.parseUtf8()
-	statusMessage.DialFrequency, err = p.parseUint64()
-	statusMessage.Mode, err = p.parseUtf8()
-	statusMessage.DxCall, err = p.parseUtf8()
-	statusMessage.Report, err = p.parseUtf8()
-	statusMessage.TxMode, err = p.parseUtf8()
-	statusMessage.TxEnabled, err = p.parseBool()
-	statusMessage.Transmitting, err = p.parseBool()
-	statusMessage.Decoding, err = p.parseBool()
-	statusMessage.RxDF, err = p.parseUint32()
-	statusMessage.TxDF, err = p.parseUint32()
-	statusMessage.DeCall, err = p.parseUtf8()
-	statusMessage.DeGrid, err = p.parseUtf8()
-	statusMessage.DxGrid, err = p.parseUtf8()
-	statusMessage.TxWatchdog, err = p.parseBool()
-	statusMessage.SubMode, err = p.parseUtf8()
-	statusMessage.FastMode, err = p.parseBool()
-	statusMessage.SpecialOperationMode, err = p.parseUint8()
-	statusMessage.FrequencyTolerance, err = p.parseUint32()
-	statusMessage.TRPeriod, err = p.parseUint32()
-	statusMessage.ConfigurationName, err = p.parseUtf8()
-	statusMessage.TxMessage, err = p.parseUtf8()
-	return statusMessage, err
-}
-
-func (p *parser) parseDecode() (DecodeMessage, error) {
-	var err error
+	statusMessage.Id = p.parseUtf8("Id")
+	statusMessage.DialFrequency = p.parseUint64("DialFrequency")
+	statusMessage.Mode = p.parseUtf8("Mode")
+	statusMessage.DxCall = p.parseUtf8("DxCall")
+	statusMessage.Report = p.parseUtf8("Report")
+	statusMessage.TxMode = p.parseUtf8("TxMode")
+	statusMessage.TxEnabled = p.parseBool("TxEnabled")
+	statusMessage.Transmitting = p.parseBool("Transmitting")
+	statusMessage.Decoding = p.parseBool("Decoding")
+	statusMessage.RxDF = p.parseUint32("RxDF")
+	statusMessage.TxDF = p.parseUint32("TxDF")
+	statusMessage.DeCall = p.parseUtf8("DeCall")
+	statusMessage.DeGrid = p.parseUtf8("DeGrid")
+	statusMessage.DxGrid = p.parseUtf8("DxGrid")
+	statusMessage.TxWatchdog = p.parseBool("TxWatchdog")
+	statusMessage.SubMode = p.parseUtf8("SubMode")
+	statusMessage.FastMode = p.parseBool("FastMode")
+	if p.exhausted() {
+		return statusMessage
+	}
+	statusMessage.SpecialOperationMode = SpecialOperationMode(p.parseUint8("SpecialOperationMode"))
+	if p.exhausted() {
+		return statusMessage
+	}
+	statusMessage.FrequencyTolerance = p.parseUint32("FrequencyTolerance")
+	statusMessage.TRPeriod = p.parseUint32("TRPeriod")
+	statusMessage.ConfigurationName = p.parseUtf8("ConfigurationName")
+	if p.exhausted() {
+		return statusMessage
+	}
+	statusMessage.TxMessage = p.parseUtf8("TxMessage")
+	return statusMessage
+}
+
+func (p *parser) parseDecode() DecodeMessage {
 	decodeMessage := DecodeMessage{}
-	decodeMessage.Id, err = p.parseUtf8()
-	decodeMessage.New, err = p.parseBool()
-	decodeMessage.Time, err = p.parseUint32()
-	decodeMessage.Snr, err = p.parseInt32()
-	decodeMessage.DeltaTimeSec, err = p.parseFloat64()
-	decodeMessage.DeltaFrequencyHz, err = p.parseUint32()
-	decodeMessage.Mode, err = p.parseUtf8()
-	decodeMessage.Message, err = p.parseUtf8()
-	decodeMessage.LowConfidence, err = p.parseBool()
-	decodeMessage.OffAir, err = p.parseBool()
-	return decodeMessage, err
-}
-
-func (p *parser) parseClear() (ClearMessage, error) {
-	var err error
+	decodeMessage.Id = p.parseUtf8("Id")
+	decodeMessage.New = p.parseBool("New")
+	decodeMessage.Time = p.parseUint32("Time")
+	decodeMessage.Snr = p.parseInt32("Snr")
+	decodeMessage.DeltaTimeSec = p.parseFloat64("DeltaTimeSec")
+	decodeMessage.DeltaFrequencyHz = p.parseUint32("DeltaFrequencyHz")
+	decodeMessage.Mode = p.parseUtf8("Mode")
+	decodeMessage.Message = p.parseUtf8("Message")
+	decodeMessage.LowConfidence = p.parseBool("LowConfidence")
+	decodeMessage.OffAir = p.parseBool("OffAir")
+	return decodeMessage
+}
+
+func (p *parser) parseClear() ClearMessage {
 	clearMessage := ClearMessage{}
-	clearMessage.Id, err = p.parseUtf8()
-	return clearMessage, err
+	clearMessage.Id = p.parseUtf8("Id")
+	if p.exhausted() {
+		// WSJT-X sends Clear without a window; servers sending it to WSJT-X include one
+		return clearMessage
+	}
+	clearMessage.Window = p.parseUint8("Window")
+	return clearMessage
+}
+
+func (p *parser) parseReply() ReplyMessage {
+	replyMessage := ReplyMessage{}
+	replyMessage.Id = p.parseUtf8("Id")
+	replyMessage.Time = p.parseUint32("Time")
+	replyMessage.Snr = p.parseInt32("Snr")
+	replyMessage.DeltaTimeSec = p.parseFloat64("DeltaTimeSec")
+	replyMessage.DeltaFrequencyHz = p.parseUint32("DeltaFrequencyHz")
+	replyMessage.Mode = p.parseUtf8("Mode")
+	replyMessage.Message = p.parseUtf8("Message")
+	replyMessage.LowConfidence = p.parseBool("LowConfidence")
+	replyMessage.Modifiers = p.parseUint8("Modifiers")
+	return replyMessage
 }
 
-func (p *parser) parseQsoLogged() (QsoLoggedMessage, error) {
-	var err error
+func (p *parser) parseQsoLogged() QsoLoggedMessage {
 	qsoLoggedMessage := QsoLoggedMessage{}
-	qsoLoggedMessage.Id, err = p.parseUtf8()
-	qsoLoggedMessage.DateTimeOff, err = p.parseQDateTime()
-	qsoLoggedMessage.DxCall, err = p.parseUtf8()
-	qsoLoggedMessage.DxGrid, err = p.parseUtf8()
-	qsoLoggedMessage.TxFrequency, err = p.parseUint64()
-	qsoLoggedMessage.Mode, err = p.parseUtf8()
-	qsoLoggedMessage.ReportSent, err = p.parseUtf8()
-	qsoLoggedMessage.ReportReceived, err = p.parseUtf8()
-	qsoLoggedMessage.TxPower, err = p.parseUtf8()
-	qsoLoggedMessage.Comments, err = p.parseUtf8()
-	qsoLoggedMessage.Name, err = p.parseUtf8()
-	qsoLoggedMessage.DateTimeOn, err = p.parseQDateTime()
-	qsoLoggedMessage.OperatorCall, err = p.parseUtf8()
-	qsoLoggedMessage.MyCall, err = p.parseUtf8()
-	qsoLoggedMessage.MyGrid, err = p.parseUtf8()
-	qsoLoggedMessage.ExchangeSent, err = p.parseUtf8()
-	qsoLoggedMessage.ExchangeReceived, err = p.parseUtf8()
-	qsoLoggedMessage.ADIFPropagationMode, err = p.parseUtf8()
-	return qsoLoggedMessage, err
-}
-
-func (p *parser) parseClose() (CloseMessage, error) {
-	var err error
+	qsoLoggedMessage.Id = p.parseUtf8("Id")
+	qsoLoggedMessage.DateTimeOff = p.parseQDateTime("DateTimeOff")
+	qsoLoggedMessage.DxCall = p.parseUtf8("DxCall")
+	qsoLoggedMessage.DxGrid = p.parseUtf8("DxGrid")
+	qsoLoggedMessage.TxFrequency = p.parseUint64("TxFrequency")
+	qsoLoggedMessage.Mode = p.parseUtf8("Mode")
+	qsoLoggedMessage.ReportSent = p.parseUtf8("ReportSent")
+	qsoLoggedMessage.ReportReceived = p.parseUtf8("ReportReceived")
+	qsoLoggedMessage.TxPower = p.parseUtf8("TxPower")
+	qsoLoggedMessage.Comments = p.parseUtf8("Comments")
+	qsoLoggedMessage.Name = p.parseUtf8("Name")
+	qsoLoggedMessage.DateTimeOn = p.parseQDateTime("DateTimeOn")
+	qsoLoggedMessage.OperatorCall = p.parseUtf8("OperatorCall")
+	qsoLoggedMessage.MyCall = p.parseUtf8("MyCall")
+	qsoLoggedMessage.MyGrid = p.parseUtf8("MyGrid")
+	if p.exhausted() {
+		return qsoLoggedMessage
+	}
+	qsoLoggedMessage.ExchangeSent = p.parseUtf8("ExchangeSent")
+	qsoLoggedMessage.ExchangeReceived = p.parseUtf8("ExchangeReceived")
+	if p.exhausted() {
+		return qsoLoggedMessage
+	}
+	qsoLoggedMessage.ADIFPropagationMode = p.parseUtf8("ADIFPropagationMode")
+	return qsoLoggedMessage
+}
+
+func (p *parser) parseClose() CloseMessage {
 	closeMessage := CloseMessage{}
-	closeMessage.Id, err = p.parseUtf8()
-	return closeMessage, err
+	closeMessage.Id = p.parseUtf8("Id")
+	return closeMessage
+}
+
+func (p *parser) parseReplay() ReplayMessage {
+	replayMessage := ReplayMessage{}
+	replayMessage.Id = p.parseUtf8("Id")
+	return replayMessage
 }
 
-func (p *parser) parseWsprDecode() (WSPRDecodeMessage, error) {
-	var err error
+func (p *parser) parseHaltTx() HaltTxMessage {
+	haltTxMessage := HaltTxMessage{}
+	haltTxMessage.Id = p.parseUtf8("Id")
+	haltTxMessage.AutoTxOnly = p.parseBool("AutoTxOnly")
+	return haltTxMessage
+}
+
+func (p *parser) parseFreeText() FreeTextMessage {
+	freeTextMessage := FreeTextMessage{}
+	freeTextMessage.Id = p.parseUtf8("Id")
+	freeTextMessage.Text = p.parseUtf8("Text")
+	freeTextMessage.Send = p.parseBool("Send")
+	return freeTextMessage
+}
+
+func (p *parser) parseWsprDecode() WSPRDecodeMessage {
 	wsprDecodeMessage := WSPRDecodeMessage{}
-	wsprDecodeMessage.Id, err = p.parseUtf8()
-	wsprDecodeMessage.New, err = p.parseBool()
-	wsprDecodeMessage.Time, err = p.parseUint32()
-	wsprDecodeMessage.Snr, err = p.parseInt32()
-	wsprDecodeMessage.DeltaTime, err = p.parseFloat64()
-	wsprDecodeMessage.Frequency, err = p.parseUint64()
-	wsprDecodeMessage.Drift, err = p.parseInt32()
-	wsprDecodeMessage.Callsign, err = p.parseUtf8()
-	wsprDecodeMessage.Grid, err = p.parseUtf8()
-	wsprDecodeMessage.Power, err = p.parseInt32()
-	wsprDecodeMessage.OffAir, err = p.parseBool()
-	return wsprDecodeMessage, err
-}
-
-func (p *parser) parseLoggedAdif() (LoggedAdifMessage, error) {
-	var err error
+	wsprDecodeMessage.Id = p.parseUtf8("Id")
+	wsprDecodeMessage.New = p.parseBool("New")
+	wsprDecodeMessage.Time = p.parseUint32("Time")
+	wsprDecodeMessage.Snr = p.parseInt32("Snr")
+	wsprDecodeMessage.DeltaTime = p.parseFloat64("DeltaTime")
+	wsprDecodeMessage.Frequency = p.parseUint64("Frequency")
+	wsprDecodeMessage.Drift = p.parseInt32("Drift")
+	wsprDecodeMessage.Callsign = p.parseUtf8("Callsign")
+	wsprDecodeMessage.Grid = p.parseUtf8("Grid")
+	wsprDecodeMessage.Power = p.parseInt32("Power")
+	wsprDecodeMessage.OffAir = p.parseBool("OffAir")
+	return wsprDecodeMessage
+}
+
+func (p *parser) parseLocation() LocationMessage {
+	locationMessage := LocationMessage{}
+	locationMessage.Id = p.parseUtf8("Id")
+	locationMessage.Location = p.parseUtf8("Location")
+	return locationMessage
+}
+
+func (p *parser) parseLoggedAdif() LoggedAdifMessage {
 	loggedAdifMessage := LoggedAdifMessage{}
-	loggedAdifMessage.Id, err = p.parseUtf8()
-	loggedAdifMessage.Adif, err = p.parseUtf8()
-	return loggedAdifMessage, err
+	loggedAdifMessage.Id = p.parseUtf8("Id")
+	loggedAdifMessage.Adif = p.parseUtf8("Adif")
+	return loggedAdifMessage
+}
+
+func (p *parser) parseHighlightCallsign() HighlightCallsignMessage {
+	highlightCallsignMessage := HighlightCallsignMessage{}
+	highlightCallsignMessage.Id = p.parseUtf8("Id")
+	highlightCallsignMessage.Callsign = p.parseUtf8("Callsign")
+	background, backgroundValid := p.parseColor("BackgroundColor")
+	foreground, foregroundValid := p.parseColor("ForegroundColor")
+	highlightCallsignMessage.BackgroundColor = background
+	highlightCallsignMessage.ForegroundColor = foreground
+	// WSJT-X clears the highlighting if either color is invalid
+	highlightCallsignMessage.Reset = p.err == nil && (!backgroundValid || !foregroundValid)
+	highlightCallsignMessage.HighlightLast = p.parseBool("HighlightLast")
+	return highlightCallsignMessage
 }
 
-func (p *parser) parseUint8() (uint8, error) {
-	if len(p.buffer) < p.cursor {
-		return 0, notEnoughBytes
+func (p *parser) parseSwitchConfiguration() SwitchConfigurationMessage {
+	switchConfigurationMessage := SwitchConfigurationMessage{}
+	switchConfigurationMessage.Id = p.parseUtf8("Id")
+	switchConfigurationMessage.ConfigurationName = p.parseUtf8("ConfigurationName")
+	return switchConfigurationMessage
+}
+
+func (p *parser) parseConfigure() ConfigureMessage {
+	configureMessage := ConfigureMessage{}
+	configureMessage.Id = p.parseUtf8("Id")
+	configureMessage.Mode = p.parseUtf8("Mode")
+	configureMessage.FrequencyTolerance = p.parseUint32("FrequencyTolerance")
+	configureMessage.Submode = p.parseUtf8("Submode")
+	configureMessage.FastMode = p.parseBool("FastMode")
+	configureMessage.TRPeriod = p.parseUint32("TRPeriod")
+	configureMessage.RxDF = p.parseUint32("RxDF")
+	configureMessage.DXCall = p.parseUtf8("DXCall")
+	configureMessage.DXGrid = p.parseUtf8("DXGrid")
+	configureMessage.GenerateMessages = p.parseBool("GenerateMessages")
+	return configureMessage
+}
+
+func (p *parser) parseAnnotationInfo() AnnotationInfoMessage {
+	annotationInfoMessage := AnnotationInfoMessage{}
+	annotationInfoMessage.Id = p.parseUtf8("Id")
+	annotationInfoMessage.DxCall = p.parseUtf8("DxCall")
+	annotationInfoMessage.SortOrderProvided = p.parseBool("SortOrderProvided")
+	annotationInfoMessage.SortOrder = p.parseUint32("SortOrder")
+	return annotationInfoMessage
+}
+
+// parseUnknown keeps the rest of a message of unknown type as its payload. Every message starts
+// with the client Id, so that is parsed too if it can be.
+func (p *parser) parseUnknown(messageType MessageType) UnknownMessage {
+	unknown := UnknownMessage{
+		TypeNumber: messageType,
+		Payload:    append([]byte(nil), p.buffer[p.cursor:p.length]...),
 	}
-	value := p.buffer[p.cursor]
-	p.cursor += 1
-	return value, nil
+	unknown.Id = p.parseUtf8("Id")
+	p.err = nil
+	p.cursor = p.length
+	return unknown
 }
 
-func (p *parser) parseUtf8() (string, error) {
-	strlen, err := p.parseUint32()
-	if err != nil {
-		return "", err
+// take returns the next n bytes of the message and advances past them. If there aren't n bytes
+// left, or an earlier field failed, it returns nil and records the failure.
+func (p *parser) take(field string, n int) []byte {
+	if p.err != nil {
+		return nil
+	}
+	if n > p.length-p.cursor {
+		p.fail(field, notEnoughBytes)
+		return nil
 	}
-	if strlen == uint32(qDataStreamNull) {
+	value := p.buffer[p.cursor : p.cursor+n]
+	p.cursor += n
+	return value
+}
+
+// fail records the first error met, along with the field and where it starts.
+func (p *parser) fail(field string, err error) {
+	if p.err == nil {
+		p.err = &FieldError{Message: p.message, Field: field, Offset: p.cursor, Err: err}
+	}
+}
+
+func (p *parser) parseUint8(field string) uint8 {
+	b := p.take(field, 1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (p *parser) parseUtf8(field string) string {
+	start := p.cursor
+	strlen := p.parseUint32(field)
+	if p.err != nil || strlen == uint32(qDataStreamNull) {
 		// this is a sentinel value meaning "null" in QDataStream, but Golang can't have nil strings
-		strlen = 0
-	}
-	end := p.cursor + int(strlen)
-	value := string(p.buffer[p.cursor:end])
-	p.cursor += int(strlen)
-	return value, nil
-}
-
-func (p *parser) parseUint32() (uint32, error) {
-	end := p.cursor + 4
-	if len(p.buffer) < end {
-		return 0, notEnoughBytes
-	}
-	value := binary.BigEndian.Uint32(p.buffer[p.cursor:end])
-	p.cursor += 4
-	return value, nil
-}
-
-func (p *parser) parseInt32() (int32, error) {
-	end := p.cursor + 4
-	if len(p.buffer) < end {
-		return 0, notEnoughBytes
-	}
-	value := int32(binary.BigEndian.Uint32(p.buffer[p.cursor:end]))
-	p.cursor += 4
-	return value, nil
-}
-
-func (p *parser) parseUint64() (uint64, error) {
-	end := p.cursor + 8
-	if len(p.buffer) < end {
-		return 0, notEnoughBytes
-	}
-	value := binary.BigEndian.Uint64(p.buffer[p.cursor:end])
-	p.cursor += 8
-	return value, nil
-}
-
-func (p *parser) parseFloat64() (float64, error) {
-	end := p.cursor + 8
-	if len(p.buffer) < end {
-		return 0, notEnoughBytes
-	}
-	bits := binary.BigEndian.Uint64(p.buffer[p.cursor:end])
-	value := math.Float64frombits(bits)
-	p.cursor += 8
-	return value, nil
-}
-
-func (p *parser) parseBool() (bool, error) {
-	if len(p.buffer) < p.cursor {
-		return false, notEnoughBytes
-	}
-	value := p.buffer[p.cursor] != 0
-	p.cursor += 1
-	return value, nil
-}
-
-func (p *parser) parseQDateTime() (time.Time, error) {
-	julianDay, err := p.parseUint64()
-	year, month, day := jdn.FromNumber(int(julianDay))
-	msMid, err := p.parseUint32()
-	msSinceMidnight := int(msMid)
-	hour := msSinceMidnight / 3600000
-	msSinceMidnight -= hour * 3600000
-	minute := msSinceMidnight / 60000
-	msSinceMidnight -= minute * 60000
-	second := msSinceMidnight / 1000
-	timespec, err := p.parseUint8()
-	var value time.Time
-	switch timespec {
-	case 0:
-		// local
-		value = time.Date(year, month, day, hour, minute, second, 0, time.Local)
-	case 1:
-		// UTC
-		value = time.Date(year, month, day, hour, minute, second, 0, time.UTC)
-	default:
-		return value, fmt.Errorf("got a timespec I wasn't expecting: %d", timespec)
+		return ""
+	}
+	if uint64(strlen) > uint64(p.length-p.cursor) {
+		p.cursor = start
+		p.fail(field, fmt.Errorf("%w: %d byte string doesn't fit", notEnoughBytes, strlen))
+		return ""
+	}
+	b := p.take(field, int(strlen))
+	if p.strings != nil {
+		return p.strings.intern(b)
+	}
+	return string(b)
+}
+
+// stringTable interns short strings, which in WSJT-X messages are mostly the same few values over
+// and over, so that they're only allocated once. It's a fixed set of slots picked by a hash of the
+// string, which is cheaper to look in than a map, and a new string just replaces whatever was in
+// its slot, so a stream of different values can't grow it.
+type stringTable struct {
+	slots [256]string
+}
+
+// The longest string interned.
+const maxInternedLen = 24
+
+func (t *stringTable) intern(b []byte) string {
+	if len(b) > maxInternedLen {
+		return string(b)
+	}
+	// FNV-1a
+	h := uint32(2166136261)
+	for _, c := range b {
+		h = (h ^ uint32(c)) * 16777619
+	}
+	slot := &t.slots[h%uint32(len(t.slots))]
+	// The compiler doesn't allocate for a string conversion used only in a comparison.
+	if *slot == string(b) {
+		return *slot
+	}
+	*slot = string(b)
+	return *slot
+}
+
+func (p *parser) parseUint16(field string) uint16 {
+	b := p.take(field, 2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (p *parser) parseUint32(field string) uint32 {
+	b := p.take(field, 4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (p *parser) parseInt32(field string) int32 {
+	return int32(p.parseUint32(field))
+}
+
+func (p *parser) parseUint64(field string) uint64 {
+	b := p.take(field, 8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (p *parser) parseFloat64(field string) float64 {
+	return math.Float64frombits(p.parseUint64(field))
+}
+
+func (p *parser) parseBool(field string) bool {
+	return p.parseUint8(field) != 0
+}
+
+// parseColor parses a QColor as a CSS hex color, and whether it is valid. Only the invalid and RGB
+// specs are understood, which is what WSJT-X and the servers talking to it use.
+func (p *parser) parseColor(field string) (string, bool) {
+	// Field type and order: https://github.com/radekp/qt/blob/b881d8fb/src/gui/painting/qcolor.cpp#L2506
+	const invalidSpec = uint8(0)
+	const rgbSpec = uint8(1)
+
+	start := p.cursor
+	spec := p.parseUint8(field)
+	a := p.parseUint16(field)
+	r := p.parseUint16(field)
+	g := p.parseUint16(field)
+	b := p.parseUint16(field)
+	p.parseUint16(field) // pad
+	if p.err != nil {
+		return "", false
+	}
+	if spec != invalidSpec && spec != rgbSpec {
+		p.cursor = start
+		p.fail(field, fmt.Errorf("%w: got a color spec I wasn't expecting: %d", ParseError, spec))
+		return "", false
+	}
+	c := csscolorparser.Color{
+		R: float64(r) / math.MaxUint16,
+		G: float64(g) / math.MaxUint16,
+		B: float64(b) / math.MaxUint16,
+		A: float64(a) / math.MaxUint16,
 	}
-	return value, err
+	return c.HexString(), spec == rgbSpec
 }
@@ -3,6 +3,7 @@ package wsjtx
 import (
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
@@ -14,7 +15,7 @@ type parseArgs struct {
 }
 
 type parseResult struct {
-	message interface{}
+	message Message
 	err     error
 }
 
@@ -60,7 +61,7 @@ func TestParseMessage(t *testing.T) {
 				FrequencyTolerance:   4294967295,
 				TRPeriod:             4294967295,
 				ConfigurationName:    "Default",
-			}, ParseError},
+			}, nil},
 		},
 		{
 			name: "Parse Status 2.3.1",
@@ -118,7 +119,7 @@ func TestParseMessage(t *testing.T) {
 			args: argsFrom(`adbccbda00000002000000050000000657534a542d5800000000002586110277ac48010000000454335354000000044a4b373300000000006bf86e00000003465438000000022d33000000022d37000000013500000007436f6d6d656e74000000034a6f6500000000002586110276c1e801000000055433535452000000054b3053574500000006444d37394c5600000002314200000002314400000003494f4e`),
 			want: parseResult{QsoLoggedMessage{
 				Id:                  "WSJT-X",
-				DateTimeOff:         parseTime("2020-10-30 11:29:57 +0000 UTC"),
+				DateTimeOff:         parseTime("2020-10-30 11:29:57.32 +0000 UTC"),
 				DxCall:              "T3ST",
 				DxGrid:              "JK73",
 				TxFrequency:         7075950,
@@ -128,7 +129,7 @@ func TestParseMessage(t *testing.T) {
 				TxPower:             "5",
 				Comments:            "Comment",
 				Name:                "Joe",
-				DateTimeOn:          parseTime("2020-10-30 11:28:57 +0000 UTC"),
+				DateTimeOn:          parseTime("2020-10-30 11:28:57.32 +0000 UTC"),
 				OperatorCall:        "T3STR",
 				MyCall:              "K0SWE",
 				MyGrid:              "DM79LV",
@@ -137,6 +138,29 @@ func TestParseMessage(t *testing.T) {
 				ADIFPropagationMode: "ION",
 			}, nil},
 		},
+		{
+			name: "Parse QSO Logged 2.4",
+			args: argsFrom(`adbccbda00000002000000050000000657534a542d5800000000002586110277ac48010000000454335354000000044a4b373300000000006bf86e00000003465438000000022d33000000022d37000000013500000007436f6d6d656e74000000034a6f6500000000002586110276c1e801000000055433535452000000054b3053574500000006444d37394c56000000023142000000023144`),
+			want: parseResult{QsoLoggedMessage{
+				Id:               "WSJT-X",
+				DateTimeOff:      parseTime("2020-10-30 11:29:57.32 +0000 UTC"),
+				DxCall:           "T3ST",
+				DxGrid:           "JK73",
+				TxFrequency:      7075950,
+				Mode:             "FT8",
+				ReportSent:       "-3",
+				ReportReceived:   "-7",
+				TxPower:          "5",
+				Comments:         "Comment",
+				Name:             "Joe",
+				DateTimeOn:       parseTime("2020-10-30 11:28:57.32 +0000 UTC"),
+				OperatorCall:     "T3STR",
+				MyCall:           "K0SWE",
+				MyGrid:           "DM79LV",
+				ExchangeSent:     "1B",
+				ExchangeReceived: "1D",
+			}, nil},
+		},
 		{
 			name: "Parse Close",
 			args: argsFrom(`adbccbda00000002000000060000000657534a542d58`),
@@ -144,6 +168,18 @@ func TestParseMessage(t *testing.T) {
 				Id: "WSJT-X",
 			}, nil},
 		},
+		{
+			name: "Parse Close schema 3",
+			args: argsFrom(`adbccbda00000003000000060000000657534a542d58`),
+			want: parseResult{CloseMessage{
+				Id: "WSJT-X",
+			}, nil},
+		},
+		{
+			name: "Reject schema 4",
+			args: argsFrom(`adbccbda00000004000000060000000657534a542d58`),
+			want: parseResult{nil, ParseError},
+		},
 		{
 			name: "Parse WSPR Decode",
 			args: argsFrom(`adbccbda000000020000000a0000000657534a542d580102b5f840ffffffeebfe000000000000000000000006b6c7300000000000000054b3654475700000004434d39350000001700`),
@@ -173,6 +209,104 @@ func TestParseMessage(t *testing.T) {
 <call:4>T3ST <gridsquare:4>JK73 <mode:3>FT8 <rst_sent:2>-8 <rst_rcvd:2>-9 <qso_date:8>20201030 <time_on:6>120816 <qso_date_off:8>20201030 <time_off:6>120916 <band:3>40m <freq:8>7.075950 <station_callsign:5>K0SWE <my_gridsquare:6>DM79LV <tx_pwr:1>5 <comment:7>Comment <name:4>Jess <operator:5>T3STR <EOR>`,
 			}, nil},
 		},
+		{
+			name: "Parse Clear with window",
+			args: argsFrom(`adbccbda00000002000000030000000657534a542d5802`),
+			want: parseResult{ClearMessage{Id: "WSJT-X", Window: 2}, nil},
+		},
+		{
+			name: "Parse Reply",
+			args: argsFrom(`adbccbda00000002000000040000000657534a542d580259baf8fffffffb3fc99999a000000000000516000000017e0000000e4a4132454a50204e3442502037330002`),
+			want: parseResult{ReplyMessage{
+				Id:               "WSJT-X",
+				Time:             39435000,
+				Snr:              -5,
+				DeltaTimeSec:     0.20000000298023224,
+				DeltaFrequencyHz: 1302,
+				Mode:             "~",
+				Message:          "JA2EJP N4BP 73",
+				LowConfidence:    false,
+				Modifiers:        2,
+			}, nil},
+		},
+		{
+			name: "Parse Replay",
+			args: argsFrom(`adbccbda00000002000000070000000657534a542d58`),
+			want: parseResult{ReplayMessage{Id: "WSJT-X"}, nil},
+		},
+		{
+			name: "Parse Halt Tx",
+			args: argsFrom(`adbccbda00000002000000080000000657534a542d5801`),
+			want: parseResult{HaltTxMessage{Id: "WSJT-X", AutoTxOnly: true}, nil},
+		},
+		{
+			name: "Parse Free Text",
+			args: argsFrom(`adbccbda00000002000000090000000657534a542d5800000010f09f988a20646520f09f87baf09f87b801`),
+			want: parseResult{FreeTextMessage{Id: "WSJT-X", Text: "😊 de 🇺🇸", Send: true}, nil},
+		},
+		{
+			name: "Parse Location",
+			args: argsFrom(`adbccbda000000020000000b0000000657534a542d5800000006444d37396875`),
+			want: parseResult{LocationMessage{Id: "WSJT-X", Location: "DM79hu"}, nil},
+		},
+		{
+			name: "Parse Highlight Callsign",
+			args: argsFrom(`adbccbda000000020000000d0000000657534a542d58000000064b4d3441434b01ffffebeb40403434000001ffff252527272e2e000001`),
+			want: parseResult{HighlightCallsignMessage{
+				Id:              "WSJT-X",
+				Callsign:        "KM4ACK",
+				BackgroundColor: "#eb4034",
+				ForegroundColor: "#25272e",
+				HighlightLast:   true,
+			}, nil},
+		},
+		{
+			name: "Parse Highlight Callsign reset",
+			args: argsFrom(`adbccbda000000020000000d0000000657534a542d58000000064b4d3441434b00ffffffffffffffff000000ffffffffffffffff000000`),
+			want: parseResult{HighlightCallsignMessage{
+				Id:              "WSJT-X",
+				Callsign:        "KM4ACK",
+				BackgroundColor: "#ffffff",
+				ForegroundColor: "#ffffff",
+				Reset:           true,
+			}, nil},
+		},
+		{
+			name: "Parse Highlight Callsign HSV color",
+			args: argsFrom(`adbccbda000000020000000d0000000657534a542d58000000064b4d3441434b02ffffebeb40403434000001ffff252527272e2e000001`),
+			want: parseResult{HighlightCallsignMessage{Id: "WSJT-X", Callsign: "KM4ACK"}, ParseError},
+		},
+		{
+			name: "Parse Switch Configuration",
+			args: argsFrom(`adbccbda000000020000000e0000000657534a542d58000000184d79416c7465726e617465436f6e66696775726174696f6e`),
+			want: parseResult{SwitchConfigurationMessage{Id: "WSJT-X", ConfigurationName: "MyAlternateConfiguration"}, nil},
+		},
+		{
+			name: "Parse Configure",
+			args: argsFrom(`adbccbda000000020000000f0000000657534a542d580000000346543400000023ffffffff010000003c000003e80000000454335354000000044a4b373301`),
+			want: parseResult{ConfigureMessage{
+				Id:                 "WSJT-X",
+				Mode:               "FT4",
+				FrequencyTolerance: 35,
+				Submode:            "",
+				FastMode:           true,
+				TRPeriod:           60,
+				RxDF:               1000,
+				DXCall:             "T3ST",
+				DXGrid:             "JK73",
+				GenerateMessages:   true,
+			}, nil},
+		},
+		{
+			name: "Parse Annotation Info",
+			args: argsFrom(`adbccbda00000002000000100000000657534a542d58000000054b314142430100000005`),
+			want: parseResult{AnnotationInfoMessage{
+				Id:                "WSJT-X",
+				DxCall:            "K1ABC",
+				SortOrderProvided: true,
+				SortOrder:         5,
+			}, nil},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -199,3 +333,288 @@ func argsFrom(str string) parseArgs {
 		length: len(bytes),
 	}
 }
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name         string
+		datagram     string
+		mode         ParseMode
+		wantMessage  Message
+		wantTrailing []byte
+		wantErr      error
+	}{
+		{
+			name:        "exact message, lenient",
+			datagram:    `adbccbda00000002000000060000000657534a542d58`,
+			mode:        Lenient,
+			wantMessage: CloseMessage{Id: "WSJT-X"},
+		},
+		{
+			name:         "trailing bytes, lenient",
+			datagram:     `adbccbda00000002000000060000000657534a542d58cafe`,
+			mode:         Lenient,
+			wantMessage:  CloseMessage{Id: "WSJT-X"},
+			wantTrailing: []byte{0xca, 0xfe},
+		},
+		{
+			name:        "trailing bytes, strict",
+			datagram:    `adbccbda00000002000000060000000657534a542d58cafe`,
+			mode:        Strict,
+			wantMessage: CloseMessage{Id: "WSJT-X"},
+			wantErr:     ParseError,
+		},
+		{
+			name:     "unknown type, lenient",
+			datagram: `adbccbda00000002000000630000000657534a542d58cafe`,
+			mode:     Lenient,
+			wantMessage: UnknownMessage{
+				Id:         "WSJT-X",
+				TypeNumber: 99,
+				Payload:    decodeHex(`0000000657534a542d58cafe`),
+			},
+		},
+		{
+			name:     "unknown type, strict",
+			datagram: `adbccbda00000002000000630000000657534a542d58cafe`,
+			mode:     Strict,
+			wantErr:  ParseError,
+		},
+		{
+			name:     "not WSJT-X, lenient",
+			datagram: `deadbeef00000002000000060000000657534a542d58`,
+			mode:     Lenient,
+			wantErr:  ParseError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, trailing, err := Parse(decodeHex(tt.datagram), tt.mode)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.wantMessage) {
+				t.Errorf("\nwant %v\ngot  %v", tt.wantMessage, got)
+			}
+			if !reflect.DeepEqual(trailing, tt.wantTrailing) {
+				t.Errorf("trailing = %x, want %x", trailing, tt.wantTrailing)
+			}
+		})
+	}
+}
+
+func TestParseFieldErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		datagram  string
+		wantField string
+		wantAt    int
+	}{
+		{
+			name:      "truncated header",
+			datagram:  `adbccbda000000`,
+			wantField: "Header.Schema",
+			wantAt:    4,
+		},
+		{
+			name:      "string length past end",
+			datagram:  `adbccbda00000002000000060000ffff57534a542d58`,
+			wantField: "Close.Id",
+			wantAt:    12,
+		},
+		{
+			name:      "string truncated mid-field",
+			datagram:  `adbccbda00000002000000050000000657534a542d5800000000002586110277ac48010000000454335354000000044a4b373300000000006bf86e00000003465438000000022d33000000022d37000000013500000007436f6d6d656e74000000034a6f6500000000002586110276c1e801000000055433535452000000054b3053574500000006444d37394c5600000002314200000002`,
+			wantField: "QsoLogged.ExchangeReceived",
+			wantAt:    148,
+		},
+		{
+			name:      "missing bool",
+			datagram:  `adbccbda00000002000000080000000657534a542d58`,
+			wantField: "HaltTx.AutoTxOnly",
+			wantAt:    22,
+		},
+		{
+			name:      "bad timespec",
+			datagram:  `adbccbda00000002000000050000000657534a542d5800000000002586110277ac4809`,
+			wantField: "QsoLogged.DateTimeOff",
+			wantAt:    22,
+		},
+		{
+			name:      "decode missing last bool",
+			datagram:  `adbccbda00000002000000020000000657534a542d58010259baf8fffffffb3fc99999a000000000000516000000017e0000000e4a4132454a50204e34425020373300`,
+			wantField: "Decode.OffAir",
+			wantAt:    67,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := argsFrom(tt.datagram)
+			_, err := parseMessage(args.buffer, args.length)
+			if !errors.Is(err, ParseError) {
+				t.Fatalf("err = %v, want a ParseError", err)
+			}
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("err = %v, want a FieldError", err)
+			}
+			if got := fieldErr.Message + "." + fieldErr.Field; got != tt.wantField {
+				t.Errorf("field = %s, want %s", got, tt.wantField)
+			}
+			if fieldErr.Offset != tt.wantAt {
+				t.Errorf("offset = %d, want %d", fieldErr.Offset, tt.wantAt)
+			}
+		})
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	messages := []Message{
+		HeartbeatMessage{Id: "WSJT-X", MaxSchema: 3, Version: "2.7.0", Revision: "abc123"},
+		StatusMessage{Id: "WSJT-X", DialFrequency: 14074000, Mode: "FT8", DxCall: "K1ABC",
+			Report: "-10", TxMode: "FT8", TxEnabled: true, RxDF: 1200, TxDF: 1500, DeCall: "K0SWE",
+			DeGrid: "DM79", DxGrid: "FN42", SpecialOperationMode: 3, FrequencyTolerance: 20,
+			TRPeriod: 15, ConfigurationName: "Default", TxMessage: "K1ABC K0SWE DM79"},
+		DecodeMessage{Id: "WSJT-X", New: true, Time: 1000, Snr: -12, DeltaTimeSec: 0.1,
+			DeltaFrequencyHz: 1500, Mode: "~", Message: "CQ K1ABC FN42", OffAir: true},
+		ClearMessage{Id: "WSJT-X", Window: 1},
+		ReplyMessage{Id: "WSJT-X", Time: 1, Snr: -20, DeltaTimeSec: 0.5, DeltaFrequencyHz: 900,
+			Mode: "~", Message: "CQ K1ABC FN42", LowConfidence: true, Modifiers: 0x02},
+		QsoLoggedMessage{Id: "WSJT-X", DateTimeOff: time.Date(2023, 6, 24, 18, 1, 30, 0, time.UTC),
+			DxCall: "K1ABC", DxGrid: "FN42", TxFrequency: 14074000, Mode: "FT8", ReportSent: "-10",
+			ReportReceived: "-12", DateTimeOn: time.Date(2023, 6, 24, 18, 0, 0, 0, time.UTC),
+			MyCall: "K0SWE", MyGrid: "DM79", ExchangeSent: "1B CO", ExchangeReceived: "2A EMA",
+			ADIFPropagationMode: "ES"},
+		CloseMessage{Id: "WSJT-X"},
+		ReplayMessage{Id: "WSJT-X"},
+		HaltTxMessage{Id: "WSJT-X", AutoTxOnly: true},
+		FreeTextMessage{Id: "WSJT-X", Text: "TNX 73", Send: true},
+		WSPRDecodeMessage{Id: "WSJT-X", New: true, Time: 2000, Snr: -25, DeltaTime: 1.5,
+			Frequency: 14097050, Drift: -1, Callsign: "K1ABC", Grid: "FN42", Power: 37},
+		LocationMessage{Id: "WSJT-X", Location: "FN42"},
+		LoggedAdifMessage{Id: "WSJT-X", Adif: "<call:5>K1ABC <EOR>"},
+		HighlightCallsignMessage{Id: "WSJT-X", Callsign: "K1ABC", BackgroundColor: "#ff0000",
+			ForegroundColor: "#000000", HighlightLast: true},
+		HighlightCallsignMessage{Id: "WSJT-X", Callsign: "VK100ANZAC", BackgroundColor: "#ffff00",
+			ForegroundColor: "#000000"},
+		SwitchConfigurationMessage{Id: "WSJT-X", ConfigurationName: "Contest"},
+		ConfigureMessage{Id: "WSJT-X", Mode: "FT8", FrequencyTolerance: 50, TRPeriod: 15, RxDF: 1500,
+			DXCall: "K1ABC", DXGrid: "FN42", GenerateMessages: true},
+		AnnotationInfoMessage{Id: "WSJT-X", DxCall: "K1ABC", SortOrderProvided: true, SortOrder: 3},
+	}
+	for _, msg := range messages {
+		t.Run(msg.Type().String(), func(t *testing.T) {
+			b, err := encode(nil, msg, defaultSchema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseMessage(b, len(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, msg) {
+				t.Errorf("\nwant %v\ngot  %v", msg, got)
+			}
+		})
+	}
+}
+
+func TestParseLengthBeyondBuffer(t *testing.T) {
+	args := argsFrom(`adbccbda00000002000000060000000657534a542d58`)
+	got, err := parseMessage(args.buffer, args.length+100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (CloseMessage{Id: "WSJT-X"}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func FuzzParseMessage(f *testing.F) {
+	seeds := []string{
+		`adbccbda00000002000000000000000657534a542d580000000300000005322e322e3200000006306439623936`,
+		`adbccbda00000002000000010000000657534a542d5800000000006bf0d000000003465438ffffffff000000032d313500000003465438000000000003730000079e000000054b3053574500000006444d37394c56ffffffff00ffffffff0000ffffffffffffffff0000000744656661756c7400000000`,
+		`adbccbda00000002000000020000000657534a542d58010259baf8fffffffb3fc99999a000000000000516000000017e0000000e4a4132454a50204e3442502037330000`,
+		`adbccbda00000002000000030000000657534a542d58`,
+		`adbccbda00000002000000050000000657534a542d5800000000002586110277ac48010000000454335354000000044a4b373300000000006bf86e00000003465438000000022d33000000022d37000000013500000007436f6d6d656e74000000034a6f6500000000002586110276c1e801000000055433535452000000054b3053574500000006444d37394c5600000002314200000002314400000003494f4e`,
+		`adbccbda000000020000000a0000000657534a542d580102b5f840ffffffeebfe000000000000000000000006b6c7300000000000000054b3654475700000004434d39350000001700`,
+		`adbccbda000000020000000c0000000657534a542d58000000083c454f523e0a`,
+		`adbccbda00000002000000630000000657534a542d58cafe`,
+	}
+	for _, seed := range seeds {
+		f.Add(decodeHex(seed))
+	}
+	f.Fuzz(func(t *testing.T, datagram []byte) {
+		for _, mode := range []ParseMode{Lenient, Strict} {
+			msg, _, err := Parse(datagram, mode)
+			if err != nil && !errors.Is(err, ParseError) {
+				t.Errorf("error doesn't wrap ParseError: %v", err)
+			}
+			if err == nil && msg == nil {
+				t.Error("no message and no error")
+			}
+		}
+	})
+}
+
+func TestParser(t *testing.T) {
+	parser := NewParser(Lenient)
+	datagram, err := Encode(DecodeMessage{Id: "WSJT-X", New: true, Mode: "~", Message: "CQ K0SWE DM79"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _, err := parser.Parse(datagram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The message is the parser's own, so the datagram can be reused.
+	copy(datagram[len(datagram)-10:], "XXXXXXXXXX")
+	want := DecodeMessage{Id: "WSJT-X", New: true, Mode: "~", Message: "CQ K0SWE DM79"}
+	if first != want {
+		t.Errorf("got %v, want %v", first, want)
+	}
+	if parser.schema != defaultSchema {
+		t.Errorf("schema %d, want %d", parser.schema, defaultSchema)
+	}
+
+	datagram, _ = Encode(HeartbeatMessage{Id: "WSJT-X", MaxSchema: 3})
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _, _ = parser.Parse(datagram)
+	})
+	// Only boxing the message as a Message allocates, not its strings.
+	if allocs > 1 {
+		t.Errorf("parsing a heartbeat again allocated %v times", allocs)
+	}
+}
+
+func TestStringTable(t *testing.T) {
+	var table stringTable
+	// More strings than slots, so some replace others.
+	for i := 0; i < 1000; i++ {
+		want := fmt.Sprintf("K%dABC", i)
+		if got := table.intern([]byte(want)); got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	b := []byte("WSJT-X")
+	table.intern(b)
+	if allocs := testing.AllocsPerRun(100, func() { table.intern(b) }); allocs > 0 {
+		t.Errorf("interning a string again allocated %v times", allocs)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	datagram := decodeHex(`adbccbda00000002000000010000000657534a542d5800000000006bf0d000000003465438ffffffff000000032d313500000003465438000000000003730000079e000000054b3053574500000006444d37394c56ffffffff00ffffffff0000ffffffffffffffff0000000744656661756c7400000000`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _, _ = Parse(datagram, Lenient)
+	}
+}
+
+func BenchmarkParser(b *testing.B) {
+	datagram := decodeHex(`adbccbda00000002000000010000000657534a542d5800000000006bf0d000000003465438ffffffff000000032d313500000003465438000000000003730000079e000000054b3053574500000006444d37394c56ffffffff00ffffffff0000ffffffffffffffff0000000744656661756c7400000000`)
+	parser := NewParser(Lenient)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _, _ = parser.Parse(datagram)
+	}
+}
@@ -0,0 +1,247 @@
+package wsjtx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/leemcloughlin/jdn"
+)
+
+// QDateTime's time specs, and the values QDataStream uses for a null date and time. Field type and
+// order: https://github.com/qt/qtbase/blob/5.15/src/corelib/time/qdatetime.cpp#L5275
+const (
+	localTimespec  = uint8(0)
+	utcTimespec    = uint8(1)
+	offsetTimespec = uint8(2)
+	zoneTimespec   = uint8(3)
+
+	nullJulianDay = uint64(1 << 63) // math.MinInt64 as a qint64
+	nullTime      = 0xffffffff
+	msPerDay      = 24 * 60 * 60 * 1000
+)
+
+// offsetFromUtcZone is the id Qt writes before the details of a fixed offset QTimeZone, as opposed
+// to one from the time zone database, which is written as just its IANA id.
+const offsetFromUtcZone = "OffsetFromUtc"
+
+// parseQDateTime parses a QDateTime, including its milliseconds. A null QDateTime is the zero time.
+// Qt 5.0's format, used by schema 1, writes the date and time in UTC whatever the spec; later ones
+// write them in the spec's own time, followed by the offset in seconds or the QTimeZone for
+// OffsetFromUTC and TimeZone specs.
+func (p *parser) parseQDateTime(field string) time.Time {
+	start := p.cursor
+	julianDay := p.parseUint64(field)
+	msSinceMidnight := p.parseUint32(field)
+	timespec := p.parseUint8(field)
+	if p.err != nil {
+		return time.Time{}
+	}
+	badTimespec := func() time.Time {
+		p.cursor = start
+		p.fail(field, fmt.Errorf("%w: got a timespec I wasn't expecting: %d", ParseError, timespec))
+		return time.Time{}
+	}
+	if p.schema == 1 {
+		if timespec != localTimespec && timespec != utcTimespec {
+			return badTimespec()
+		}
+		t := p.qDateTimeIn(field, start, julianDay, msSinceMidnight, time.UTC)
+		if timespec == localTimespec && !t.IsZero() {
+			t = t.Local()
+		}
+		return t
+	}
+
+	var loc *time.Location
+	switch timespec {
+	case localTimespec:
+		loc = time.Local
+	case utcTimespec:
+		loc = time.UTC
+	case offsetTimespec:
+		offset := p.parseInt32(field)
+		loc = time.FixedZone("", int(offset))
+	case zoneTimespec:
+		loc = p.parseQTimeZone(field)
+	default:
+		return badTimespec()
+	}
+	if p.err != nil {
+		return time.Time{}
+	}
+	return p.qDateTimeIn(field, start, julianDay, msSinceMidnight, loc)
+}
+
+// qDateTimeIn makes a time from a QDate and QTime in the given location.
+func (p *parser) qDateTimeIn(
+	field string, start int, julianDay uint64, msMid uint32, loc *time.Location) time.Time {
+	if julianDay == nullJulianDay || msMid == nullTime {
+		return time.Time{}
+	}
+	if msMid >= msPerDay {
+		p.cursor = start
+		p.fail(field, fmt.Errorf("%w: %d ms is past the end of the day", ParseError, msMid))
+		return time.Time{}
+	}
+	year, month, day := jdn.FromNumber(int(int64(julianDay)))
+	ms := time.Duration(msMid) * time.Millisecond
+	hour := int(ms / time.Hour)
+	minute := int(ms % time.Hour / time.Minute)
+	second := int(ms % time.Minute / time.Second)
+	nanosecond := int(ms % time.Second)
+	return time.Date(year, month, day, hour, minute, second, nanosecond, loc)
+}
+
+// parseQTimeZone parses a QTimeZone: either the IANA id of a zone from the time zone database, or
+// a fixed offset from UTC along with its names. A zone missing from this system's database is taken
+// as UTC, named with its id, since the datagram doesn't say its offset.
+func (p *parser) parseQTimeZone(field string) *time.Location {
+	id := p.parseQString(field)
+	if p.err != nil {
+		return nil
+	}
+	if id == offsetFromUtcZone {
+		id = p.parseQString(field)
+		offset := p.parseInt32(field)
+		name := p.parseQString(field)
+		p.parseQString(field) // abbreviation
+		p.parseInt32(field)   // country
+		p.parseQString(field) // comment
+		if name == "" {
+			name = id
+		}
+		return time.FixedZone(name, int(offset))
+	}
+	if p.zones != nil {
+		return p.zones.load(id)
+	}
+	return loadZone(id)
+}
+
+func loadZone(id string) *time.Location {
+	loc, err := time.LoadLocation(id)
+	if err != nil {
+		return time.FixedZone(id, 0)
+	}
+	return loc
+}
+
+// zoneTable keeps the time zones a Parser has loaded, as loading one reads the time zone database
+// each time.
+type zoneTable struct {
+	m map[string]*time.Location
+}
+
+// How many zones are kept before starting again, so that a stream of different ids can't grow the
+// table without bound.
+const maxZones = 64
+
+func (t *zoneTable) load(id string) *time.Location {
+	if loc, ok := t.m[id]; ok {
+		return loc
+	}
+	if t.m == nil || len(t.m) >= maxZones {
+		t.m = make(map[string]*time.Location)
+	}
+	loc := loadZone(id)
+	t.m[id] = loc
+	return loc
+}
+
+// parseQString parses a QString, which unlike the UTF-8 strings in WSJT-X's messages is UTF-16.
+func (p *parser) parseQString(field string) string {
+	start := p.cursor
+	bytelen := p.parseUint32(field)
+	if p.err != nil || bytelen == uint32(qDataStreamNull) {
+		return ""
+	}
+	if uint64(bytelen) > uint64(p.length-p.cursor) || bytelen%2 != 0 {
+		p.cursor = start
+		p.fail(field, fmt.Errorf("%w: %d byte UTF-16 string doesn't fit", notEnoughBytes, bytelen))
+		return ""
+	}
+	b := p.take(field, int(bytelen))
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// encodeQDateTime writes the time as a QDateTime, including its milliseconds. Local and UTC times
+// keep those specs. A time in a zone from the time zone database is written with that zone, and
+// any other time with its offset from UTC. Schema 1 only has local and UTC time, so other times are
+// written as UTC. The zero time is written as a null QDateTime.
+func (e *encoder) encodeQDateTime(field string, t time.Time) {
+	if t.IsZero() {
+		e.encodeUint64(nullJulianDay)
+		e.encodeUint32(nullTime)
+		e.encodeUint8(localTimespec)
+		return
+	}
+	loc := t.Location()
+	timespec := zoneTimespec
+	switch {
+	case loc == time.Local:
+		timespec = localTimespec
+	case loc == time.UTC || e.schema == 1:
+		timespec = utcTimespec
+		t = t.UTC()
+	case !inZoneDatabase(loc):
+		timespec = offsetTimespec
+	}
+	wallClock := t
+	if e.schema == 1 {
+		wallClock = t.UTC()
+	}
+
+	year, month, day := wallClock.Date()
+	hour, minute, second := wallClock.Clock()
+	ms := wallClock.Nanosecond() / int(time.Millisecond)
+	msSinceMidnight := ((hour*60+minute)*60+second)*1000 + ms
+	e.encodeUint64(uint64(jdn.ToNumber(year, month, day)))
+	e.encodeUint32(uint32(msSinceMidnight))
+	e.encodeUint8(timespec)
+	switch timespec {
+	case offsetTimespec:
+		_, offset := t.Zone()
+		e.encodeInt32(int32(offset))
+	case zoneTimespec:
+		e.encodeQString(field, loc.String())
+	}
+}
+
+// zoneDatabaseNames remembers which location names inZoneDatabase has looked up, and whether they
+// were found.
+var zoneDatabaseNames sync.Map
+
+// inZoneDatabase reports whether the location is a zone from the time zone database, rather than
+// e.g. a fixed offset, so that WSJT-X can look it up by name.
+func inZoneDatabase(loc *time.Location) bool {
+	name := loc.String()
+	if name == "" || name == "Local" {
+		return false
+	}
+	if found, ok := zoneDatabaseNames.Load(name); ok {
+		return found.(bool)
+	}
+	_, err := time.LoadLocation(name)
+	zoneDatabaseNames.Store(name, err == nil)
+	return err == nil
+}
+
+// encodeQString writes a QString, which is UTF-16.
+func (e *encoder) encodeQString(field string, str string) {
+	units := utf16.Encode([]rune(str))
+	if 2*len(units) > MaxDatagramSize {
+		e.fail(field, fmt.Errorf("%w: %d UTF-16 code units", StringTooLongError, len(units)))
+		return
+	}
+	e.encodeUint32(uint32(2 * len(units)))
+	for _, u := range units {
+		e.encodeUint16(u)
+	}
+}
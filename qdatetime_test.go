@@ -0,0 +1,181 @@
+package wsjtx
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQDateTimeRoundTrip(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	tests := []struct {
+		name   string
+		time   time.Time
+		schema uint32
+	}{
+		{"UTC", time.Date(2020, 10, 30, 11, 29, 57, 320000000, time.UTC), 2},
+		{"local", time.Date(2020, 10, 30, 11, 29, 57, 320000000, time.Local), 2},
+		{"offset", time.Date(2020, 10, 30, 17, 59, 57, 0, time.FixedZone("", 5*3600+1800)), 3},
+		{"time zone", time.Date(2020, 7, 4, 23, 59, 59, 999000000, denver), 3},
+		{"zero", time.Time{}, 2},
+		{"schema 1 local", time.Date(2020, 10, 30, 23, 30, 0, 0, time.Local), 1},
+		{"schema 1 UTC", time.Date(2020, 10, 30, 23, 30, 0, 0, time.UTC), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := EncodeSchema(QsoLoggedMessage{Id: "WSJT-X", DateTimeOff: tt.time}, tt.schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, _, err := Parse(b, Strict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := msg.(QsoLoggedMessage).DateTimeOff
+			if !got.Equal(tt.time) || got.Location().String() != tt.time.Location().String() {
+				t.Errorf("got %v, want %v", got, tt.time)
+			}
+			_, gotOffset := got.Zone()
+			if _, wantOffset := tt.time.Zone(); gotOffset != wantOffset {
+				t.Errorf("got offset %d, want %d", gotOffset, wantOffset)
+			}
+		})
+	}
+}
+
+func TestParseQDateTime(t *testing.T) {
+	// 2020-10-30 11:29:57.320 as a QDate and QTime.
+	const julianDay, ms = 2459153, 41397320
+	qDateTime := func(timespec uint8, rest ...byte) []byte {
+		b := decodeHex("adbccbda00000003000000050000000657534a542d58")
+		b = binary.BigEndian.AppendUint64(b, julianDay)
+		b = binary.BigEndian.AppendUint32(b, ms)
+		b = append(b, timespec)
+		return append(b, rest...)
+	}
+	qString := func(s string) []byte {
+		b := binary.BigEndian.AppendUint32(nil, uint32(2*len(s)))
+		for _, r := range s {
+			b = binary.BigEndian.AppendUint16(b, uint16(r))
+		}
+		return b
+	}
+	join := func(parts ...[]byte) []byte {
+		var b []byte
+		for _, part := range parts {
+			b = append(b, part...)
+		}
+		return b
+	}
+	offset := []byte{0xff, 0xff, 0x9d, 0x90} // -25200 seconds
+	mountain := time.FixedZone("UTC-07:00", -7*3600)
+	tests := []struct {
+		name     string
+		datagram []byte
+		want     time.Time
+		wantErr  bool
+	}{
+		{
+			name:     "offset from UTC",
+			datagram: qDateTime(2, offset...),
+			want:     time.Date(2020, 10, 30, 11, 29, 57, 320000000, time.FixedZone("", -7*3600)),
+		},
+		{
+			name: "fixed offset time zone",
+			datagram: qDateTime(3, join(
+				qString("OffsetFromUtc"),
+				qString("UTC-07:00"), // id
+				offset,
+				qString("UTC-07:00"),           // name
+				qString(""),                    // abbreviation
+				[]byte{0, 0, 0, 0},             // country
+				[]byte{0xff, 0xff, 0xff, 0xff}, // null comment
+			)...),
+			want: time.Date(2020, 10, 30, 11, 29, 57, 320000000, mountain),
+		},
+		{
+			name:     "unknown time zone",
+			datagram: qDateTime(3, qString("Nowhere/Special")...),
+			want: time.Date(2020, 10, 30, 11, 29, 57, 320000000,
+				time.FixedZone("Nowhere/Special", 0)),
+		},
+		{
+			name:     "unknown timespec",
+			datagram: qDateTime(4),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newParser(tt.datagram, len(tt.datagram), Lenient)
+			p.schema = 3
+			p.cursor = 22 // after the header and Id
+			got := p.parseQDateTime("DateTimeOff")
+			if tt.wantErr {
+				if !errors.Is(p.err, ParseError) {
+					t.Errorf("error = %v, want a ParseError", p.err)
+				}
+				return
+			}
+			if p.err != nil {
+				t.Fatal(p.err)
+			}
+			if !got.Equal(tt.want) || got.Location().String() != tt.want.Location().String() {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if p.cursor != len(tt.datagram) {
+				t.Errorf("read %d bytes of %d", p.cursor, len(tt.datagram))
+			}
+		})
+	}
+}
+
+func TestParserKeepsZones(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	b, err := EncodeSchema(QsoLoggedMessage{Id: "WSJT-X",
+		DateTimeOff: time.Date(2020, 7, 4, 23, 59, 59, 0, denver)}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewParser(Strict)
+	var locs []*time.Location
+	for i := 0; i < 2; i++ {
+		msg, _, err := p.Parse(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		locs = append(locs, msg.(QsoLoggedMessage).DateTimeOff.Location())
+	}
+	if locs[0] != locs[1] {
+		t.Error("loaded America/Denver again")
+	}
+}
+
+func TestEncodeQString(t *testing.T) {
+	tests := []struct {
+		name    string
+		str     string
+		wantErr bool
+	}{
+		// Three UTF-8 bytes, but one UTF-16 code unit each.
+		{"fits as UTF-16", strings.Repeat("€", MaxDatagramSize/2), false},
+		{"too long as UTF-16", strings.Repeat("x", MaxDatagramSize/2+1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEncoder(nil, 3, QsoLoggedType)
+			e.encodeQString("TimeZone", tt.str)
+			if tt.wantErr != errors.Is(e.err, StringTooLongError) {
+				t.Errorf("error = %v, want StringTooLongError: %v", e.err, tt.wantErr)
+			}
+		})
+	}
+}
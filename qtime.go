@@ -0,0 +1,61 @@
+package wsjtx
+
+import (
+	"fmt"
+	"time"
+)
+
+// qTimeAt resolves a QTime, which is how decodes and replies give their Time: milliseconds since
+// midnight UTC, with no date. The date is taken from when it was received. A decode received just
+// after midnight, or by a server whose clock is a little behind, may belong to the day before or
+// after the one it was received on, so the day is chosen to keep it within 12 hours of receipt.
+func qTimeAt(ms uint32, received time.Time) time.Time {
+	received = received.UTC()
+	year, month, day := received.Date()
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Add(time.Duration(ms) * time.Millisecond)
+	switch {
+	case t.Sub(received) > 12*time.Hour:
+		t = t.AddDate(0, 0, -1)
+	case received.Sub(t) > 12*time.Hour:
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// formatQTime formats a QTime as HHMMSS, as in WSJT-X's Band Activity window.
+func formatQTime(ms uint32) string {
+	seconds := ms / 1000
+	return fmt.Sprintf("%02d%02d%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// Timestamp returns when the decoded period began, in UTC, on the day nearest to received, which
+// is usually when the message arrived.
+func (m DecodeMessage) Timestamp(received time.Time) time.Time {
+	return qTimeAt(m.Time, received)
+}
+
+// Clock returns the Time as HHMMSS in UTC, as WSJT-X shows it.
+func (m DecodeMessage) Clock() string {
+	return formatQTime(m.Time)
+}
+
+// Timestamp returns when the decoded period began, in UTC, on the day nearest to received.
+func (m ReplyMessage) Timestamp(received time.Time) time.Time {
+	return qTimeAt(m.Time, received)
+}
+
+// Clock returns the Time as HHMMSS in UTC, as WSJT-X shows it.
+func (m ReplyMessage) Clock() string {
+	return formatQTime(m.Time)
+}
+
+// Timestamp returns when the decoded period began, in UTC, on the day nearest to received, which
+// is usually when the message arrived.
+func (m WSPRDecodeMessage) Timestamp(received time.Time) time.Time {
+	return qTimeAt(m.Time, received)
+}
+
+// Clock returns the Time as HHMMSS in UTC, as WSJT-X shows it.
+func (m WSPRDecodeMessage) Clock() string {
+	return formatQTime(m.Time)
+}
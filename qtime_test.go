@@ -0,0 +1,72 @@
+package wsjtx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeTimestamp(t *testing.T) {
+	at := func(hour, minute, second int) uint32 {
+		return uint32(((hour*60+minute)*60 + second) * 1000)
+	}
+	tests := []struct {
+		name     string
+		time     uint32
+		received time.Time
+		want     time.Time
+	}{
+		{
+			name:     "same day",
+			time:     at(18, 1, 30),
+			received: time.Date(2023, 6, 24, 18, 1, 44, 0, time.UTC),
+			want:     time.Date(2023, 6, 24, 18, 1, 30, 0, time.UTC),
+		},
+		{
+			name:     "received after midnight",
+			time:     at(23, 59, 45),
+			received: time.Date(2023, 6, 25, 0, 0, 1, 0, time.UTC),
+			want:     time.Date(2023, 6, 24, 23, 59, 45, 0, time.UTC),
+		},
+		{
+			name:     "clock behind at midnight",
+			time:     at(0, 0, 0),
+			received: time.Date(2023, 6, 24, 23, 59, 58, 0, time.UTC),
+			want:     time.Date(2023, 6, 25, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "received in another zone",
+			time:     at(23, 59, 45),
+			received: time.Date(2023, 6, 24, 18, 0, 1, 0, time.FixedZone("MDT", -6*3600)),
+			want:     time.Date(2023, 6, 24, 23, 59, 45, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (DecodeMessage{Time: tt.time}).Timestamp(tt.received); !got.Equal(tt.want) {
+				t.Errorf("DecodeMessage.Timestamp() = %v, want %v", got, tt.want)
+			}
+			if got := (ReplyMessage{Time: tt.time}).Timestamp(tt.received); !got.Equal(tt.want) {
+				t.Errorf("ReplyMessage.Timestamp() = %v, want %v", got, tt.want)
+			}
+			if got := (WSPRDecodeMessage{Time: tt.time}).Timestamp(tt.received); !got.Equal(tt.want) {
+				t.Errorf("WSPRDecodeMessage.Timestamp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeClock(t *testing.T) {
+	tests := []struct {
+		time uint32
+		want string
+	}{
+		{0, "000000"},
+		{39435000, "105715"},
+		{86399999, "235959"},
+	}
+	for _, tt := range tests {
+		if got := (DecodeMessage{Time: tt.time}).Clock(); got != tt.want {
+			t.Errorf("Clock() of %d = %s, want %s", tt.time, got, tt.want)
+		}
+	}
+}
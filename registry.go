@@ -0,0 +1,257 @@
+package wsjtx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ClientInfo describes a WSJT-X instance which the server has heard from. Instances are told apart
+// by the Id field carried in every message, so several WSJT-X programs can share one server.
+//
+// MaxSchema is the highest schema the client advertised in its heartbeat, and Schema is the one
+// used to talk to it: the lower of the client's and the server's maximum, as negotiated by WSJT-X
+// itself, or the schema of the client's latest message if it hasn't sent a heartbeat yet.
+//
+// Stale is set when the client has missed too many heartbeats, if the server checks for that, and
+// cleared when it's heard from again.
+type ClientInfo struct {
+	Id        string       `json:"id"`
+	Addr      *net.UDPAddr `json:"addr"`
+	MaxSchema uint32       `json:"maxSchemaVersion"`
+	Schema    uint32       `json:"schemaVersion"`
+	Version   string       `json:"version"`
+	Revision  string       `json:"revision"`
+	LastSeen  time.Time    `json:"lastSeen"`
+	Stale     bool         `json:"stale"`
+}
+
+// ClientEventType says what happened to a client in a ClientEvent.
+type ClientEventType int
+
+const (
+	// ClientConnected is sent the first time a message arrives from a client Id.
+	ClientConnected ClientEventType = iota
+	// ClientDisconnected is sent when a client announces that it is closing, or has missed so many
+	// heartbeats that it's presumed gone.
+	ClientDisconnected
+	// ClientStale is sent when a client has missed enough heartbeats that it may be gone.
+	ClientStale
+	// ClientRevived is sent when a stale client is heard from again.
+	ClientRevived
+)
+
+func (t ClientEventType) String() string {
+	switch t {
+	case ClientConnected:
+		return "connected"
+	case ClientDisconnected:
+		return "disconnected"
+	case ClientStale:
+		return "stale"
+	case ClientRevived:
+		return "revived"
+	}
+	return "unknown"
+}
+
+// ClientEvent reports a WSJT-X instance appearing, going quiet or going away.
+type ClientEvent struct {
+	Type   ClientEventType `json:"type"`
+	Client ClientInfo      `json:"client"`
+}
+
+// ClientEventDroppedError is reported when a channel passed to WatchClients has fallen so far
+// behind that an event for it had to be dropped.
+var ClientEventDroppedError = errors.New("client event dropped, the watcher isn't keeping up")
+
+// watchBuffer is how many events can wait for each watcher before more are dropped.
+const watchBuffer = 16
+
+// clientRegistry tracks every WSJT-X instance the server has heard from, keyed by Id.
+type clientRegistry struct {
+	mu        sync.RWMutex
+	clients   map[string]*ClientInfo
+	watchers  []chan ClientEvent
+	done      chan struct{}
+	closeOnce sync.Once
+	maxSchema uint32
+}
+
+func newClientRegistry(maxSchema uint32) *clientRegistry {
+	return &clientRegistry{
+		clients:   map[string]*ClientInfo{},
+		done:      make(chan struct{}),
+		maxSchema: maxSchema,
+	}
+}
+
+// observe records that the given message arrived from addr with the given schema in its header,
+// and notifies watchers if that means a client came or went.
+func (r *clientRegistry) observe(
+	message Message, schema uint32, addr *net.UDPAddr, now time.Time) error {
+	id := message.ClientId()
+	if id == "" {
+		return nil
+	}
+	if _, ok := message.(CloseMessage); ok {
+		return r.remove(id)
+	}
+
+	r.mu.Lock()
+	client, known := r.clients[id]
+	if !known {
+		client = &ClientInfo{Id: id}
+		r.clients[id] = client
+	}
+	revived := client.Stale
+	client.Addr = addr
+	client.LastSeen = now
+	client.Stale = false
+	if hb, ok := message.(HeartbeatMessage); ok {
+		client.MaxSchema = hb.MaxSchema
+		client.Version = hb.Version
+		client.Revision = hb.Revision
+	}
+	if client.MaxSchema == 0 {
+		client.Schema = schema
+	} else {
+		client.Schema = negotiateSchema(client.MaxSchema, r.maxSchema)
+	}
+	info := *client
+	r.mu.Unlock()
+
+	if !known {
+		return r.notify(ClientEvent{ClientConnected, info})
+	} else if revived {
+		return r.notify(ClientEvent{ClientRevived, info})
+	}
+	return nil
+}
+
+// sweep marks clients which haven't been heard from for staleAfter as stale, and removes those
+// which haven't been heard from for disconnectAfter, notifying watchers of each. A zero duration
+// disables that check.
+func (r *clientRegistry) sweep(now time.Time, staleAfter, disconnectAfter time.Duration) []error {
+	var events []ClientEvent
+	r.mu.Lock()
+	for id, client := range r.clients {
+		quiet := now.Sub(client.LastSeen)
+		switch {
+		case disconnectAfter > 0 && quiet >= disconnectAfter:
+			delete(r.clients, id)
+			events = append(events, ClientEvent{ClientDisconnected, *client})
+		case staleAfter > 0 && quiet >= staleAfter && !client.Stale:
+			client.Stale = true
+			events = append(events, ClientEvent{ClientStale, *client})
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Client.Id < events[j].Client.Id })
+	var errs []error
+	for _, event := range events {
+		if err := r.notify(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (r *clientRegistry) remove(id string) error {
+	r.mu.Lock()
+	client, known := r.clients[id]
+	delete(r.clients, id)
+	r.mu.Unlock()
+
+	if !known {
+		return nil
+	}
+	return r.notify(ClientEvent{ClientDisconnected, *client})
+}
+
+func (r *clientRegistry) lookup(id string) (ClientInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	client, ok := r.clients[id]
+	if !ok {
+		return ClientInfo{}, false
+	}
+	return *client, true
+}
+
+// list returns a snapshot of the known clients, ordered by Id.
+func (r *clientRegistry) list() []ClientInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clients := make([]ClientInfo, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, *client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Id < clients[j].Id })
+	return clients
+}
+
+// watch starts passing events to c. They're queued for it and sent from a goroutine of its own, so
+// that a watcher which is slow to read, or never does, can't hold up the server; the goroutine
+// stops once the registry is closed.
+func (r *clientRegistry) watch(c chan<- ClientEvent) {
+	queue := make(chan ClientEvent, watchBuffer)
+	r.mu.Lock()
+	r.watchers = append(r.watchers, queue)
+	r.mu.Unlock()
+	go func() {
+		for {
+			select {
+			case event := <-queue:
+				select {
+				case c <- event:
+				case <-r.done:
+					return
+				}
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+// notify queues the event for every watcher, dropping it for those whose queue is full.
+func (r *clientRegistry) notify(event ClientEvent) error {
+	r.mu.RLock()
+	watchers := r.watchers
+	r.mu.RUnlock()
+	dropped := 0
+	for _, queue := range watchers {
+		select {
+		case queue <- event:
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		return fmt.Errorf("%w: %s %s for %d of %d watchers", ClientEventDroppedError,
+			event.Client.Id, event.Type, dropped, len(watchers))
+	}
+	return nil
+}
+
+// close stops passing events to watchers.
+func (r *clientRegistry) close() {
+	r.closeOnce.Do(func() { close(r.done) })
+}
+
+// negotiateSchema picks the highest schema both sides understand.
+func negotiateSchema(clientMax, serverMax uint32) uint32 {
+	schema := clientMax
+	if serverMax < schema {
+		schema = serverMax
+	}
+	if schema < minSchema {
+		schema = minSchema
+	}
+	return schema
+}
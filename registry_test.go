@@ -0,0 +1,56 @@
+package wsjtx
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestClientRegistryWatcherNeverReads(t *testing.T) {
+	registry := newClientRegistry(maxSchema)
+	defer registry.close()
+	registry.watch(make(chan ClientEvent))
+	events := make(chan ClientEvent, 100)
+	registry.watch(events)
+
+	// Many more clients than fit in a watcher's queue connect, which mustn't wait on the watcher
+	// which never reads.
+	done := make(chan []error)
+	go func() {
+		var errs []error
+		for i := 0; i < 4*watchBuffer; i++ {
+			msg := ClearMessage{Id: fmt.Sprintf("WSJT-X - rig%d", i)}
+			if err := registry.observe(msg, 2, nil, time.Now()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		done <- errs
+	}()
+	var errs []error
+	select {
+	case errs = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("observe is blocked on a watcher")
+	}
+	if len(errs) == 0 {
+		t.Error("no events were reported dropped")
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ClientEventDroppedError) {
+			t.Errorf("error = %v, want a ClientEventDroppedError", err)
+		}
+	}
+
+	// The watcher which does read still gets the events that fitted in its queue.
+	for i := 0; i < watchBuffer; i++ {
+		select {
+		case event := <-events:
+			if want := fmt.Sprintf("WSJT-X - rig%d", i); event.Client.Id != want {
+				t.Errorf("got %s, want %s", event.Client.Id, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("got %d events, want at least %d", i, watchBuffer)
+		}
+	}
+}
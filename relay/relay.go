@@ -0,0 +1,229 @@
+// Package relay fans WSJT-X traffic out to several servers. WSJT-X only sends to one UDP address,
+// so a relay listens there and copies every datagram, unchanged, to each downstream server such as
+// GridTracker, JTAlert or a logger. Messages the downstream servers send back are passed on to the
+// WSJT-X instance they're addressed to, if that server is allowed to send that type of message.
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+// AllowAll in a Destination's Allow list permits every type of message.
+const AllowAll = "*"
+
+// NotAllowedError is reported when a downstream server sends a type of message which it isn't
+// allowed to send to WSJT-X.
+var NotAllowedError = errors.New("message type not allowed upstream")
+
+// Destination is a downstream server which the relay copies WSJT-X traffic to.
+type Destination struct {
+	// Name identifies the destination in errors. It defaults to the address.
+	Name string
+	Addr *net.UDPAddr
+	// Allow lists the types of message, by name such as "Reply" or "HighlightCallsign", which this
+	// destination may send to WSJT-X. AllowAll permits every type. Nothing is allowed by default,
+	// so that a destination can't e.g. halt transmission unless it's trusted to.
+	Allow []string
+}
+
+// destination is a Destination along with the socket the relay uses to talk to it. Each
+// destination gets its own socket, so that replies are known to come from it.
+type destination struct {
+	Destination
+	conn     *net.UDPConn
+	allowed  map[wsjtx.MessageType]bool
+	allowAll bool
+}
+
+// Relay copies datagrams from WSJT-X, as heard by a Server, to every destination, and passes
+// allowed messages from the destinations back through the Server.
+type Relay struct {
+	server       *wsjtx.Server
+	destinations []*destination
+
+	mu            sync.RWMutex
+	errorHandlers []func(error)
+}
+
+// New creates a relay between the server and the destinations, opening a socket for each
+// destination. It returns an error if an allow list names an unknown message type.
+func New(server *wsjtx.Server, destinations []Destination) (*Relay, error) {
+	r := &Relay{server: server}
+	for _, d := range destinations {
+		dest, err := newDestination(d)
+		if err != nil {
+			r.close()
+			return nil, err
+		}
+		r.destinations = append(r.destinations, dest)
+	}
+	server.OnDatagram(r.fanOut)
+	return r, nil
+}
+
+func newDestination(d Destination) (*destination, error) {
+	if d.Addr == nil {
+		return nil, fmt.Errorf("destination %q has no address", d.Name)
+	}
+	if d.Name == "" {
+		d.Name = d.Addr.String()
+	}
+	dest := &destination{Destination: d, allowed: map[wsjtx.MessageType]bool{}}
+	for _, name := range d.Allow {
+		if name == AllowAll {
+			dest.allowAll = true
+			continue
+		}
+		t, ok := messageTypeNamed(name)
+		if !ok {
+			return nil, fmt.Errorf("destination %s allows unknown message type %q", d.Name, name)
+		}
+		dest.allowed[t] = true
+	}
+	conn, err := net.ListenUDP(d.Addr.Network(), nil)
+	if err != nil {
+		return nil, err
+	}
+	dest.conn = conn
+	return dest, nil
+}
+
+// messageTypeNamed finds the message type with the given name, as returned by MessageType.String.
+func messageTypeNamed(name string) (wsjtx.MessageType, bool) {
+	for t := wsjtx.HeartbeatType; t <= wsjtx.AnnotationInfoType; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// OnError registers a handler for problems which don't stop the relay, such as a destination
+// being unreachable or sending a message it isn't allowed to.
+func (r *Relay) OnError(h func(error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errorHandlers = append(r.errorHandlers, h)
+}
+
+func (r *Relay) report(err error) {
+	r.mu.RLock()
+	errorHandlers := r.errorHandlers
+	r.mu.RUnlock()
+	for _, h := range errorHandlers {
+		h(err)
+	}
+}
+
+// Run listens on the server and on every destination's socket until the context is cancelled or
+// the server fails, whose error is returned. Problems with a destination are only reported. The destination sockets are closed when Run returns, so
+// a relay can only be run once; the server is left open.
+func (r *Relay) Run(ctx context.Context) error {
+	defer r.close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(r.destinations)+1)
+	go func() { errs <- r.server.Listen(ctx, nil, nil) }()
+	for _, dest := range r.destinations {
+		dest := dest
+		go func() { errs <- r.passBack(ctx, dest) }()
+	}
+
+	// Whatever stops first, e.g. the server being shut down, stops the rest.
+	var err error
+	for i := 0; i < len(r.destinations)+1; i++ {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+		cancel()
+	}
+	return err
+}
+
+func (r *Relay) close() {
+	for _, dest := range r.destinations {
+		_ = dest.conn.Close()
+	}
+}
+
+// fanOut copies a datagram from WSJT-X to every destination.
+func (r *Relay) fanOut(datagram []byte, _ *net.UDPAddr, _ wsjtx.Message, _ []byte) {
+	for _, dest := range r.destinations {
+		if _, err := dest.conn.WriteTo(datagram, dest.Addr); err != nil {
+			r.report(fmt.Errorf("relaying to %s: %w", dest.Name, err))
+		}
+	}
+}
+
+// passBack reads the destination's socket until the context is done, passing allowed messages on
+// to the WSJT-X instance they're addressed to. Problems with the destination are reported, and
+// don't stop it.
+func (r *Relay) passBack(ctx context.Context, dest *destination) error {
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = dest.conn.Close()
+		case <-stopped:
+		}
+	}()
+
+	// Each datagram is finished with before the next read, so one buffer does.
+	b := make([]byte, wsjtx.MaxDatagramSize)
+	parser := wsjtx.NewParser(wsjtx.Lenient)
+	for {
+		length, from, err := dest.conn.ReadFromUDP(b)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			// e.g. the destination isn't listening, which Windows reports on the next read. The
+			// other destinations carry on regardless.
+			r.report(fmt.Errorf("reading from %s: %w", dest.Name, err))
+			continue
+		}
+		if !dest.sentBy(from) {
+			continue
+		}
+		datagram := b[:length]
+		msg, _, err := parser.Parse(datagram)
+		if err != nil {
+			r.report(fmt.Errorf("from %s: %w", dest.Name, err))
+			continue
+		}
+		// Every server sends heartbeats, which are for the relay rather than WSJT-X, whose schema
+		// is negotiated with the relay's own server.
+		if msg.Type() == wsjtx.HeartbeatType {
+			continue
+		}
+		if !dest.allows(msg.Type()) {
+			r.report(fmt.Errorf("%w: %s from %s", NotAllowedError, msg.Type(), dest.Name))
+			continue
+		}
+		if err := r.server.SendDatagram(msg.ClientId(), datagram); err != nil {
+			r.report(fmt.Errorf("passing %s from %s: %w", msg.Type(), dest.Name, err))
+		}
+	}
+}
+
+// sentBy reports whether a datagram from the given address came from the destination. A
+// destination which is a multicast group replies from its own address, and one without an IP
+// address could reply from any, so anything is accepted from those.
+func (d *destination) sentBy(from *net.UDPAddr) bool {
+	if d.Addr.IP == nil || d.Addr.IP.IsMulticast() || d.Addr.IP.IsUnspecified() {
+		return true
+	}
+	return from.Port == d.Addr.Port && from.IP.Equal(d.Addr.IP)
+}
+
+func (d *destination) allows(t wsjtx.MessageType) bool {
+	return d.allowAll || d.allowed[t]
+}
@@ -0,0 +1,127 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/k0swe/wsjtx-go/v4"
+)
+
+// listening makes a server on a free localhost port and listens on it until the test ends.
+func listening(t *testing.T) (*wsjtx.Server, chan wsjtx.Message) {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := make(chan wsjtx.Message, 10)
+	go server.ListenToWsjtx(msgs, nil)
+	t.Cleanup(func() { _ = server.Shutdown(context.Background()) })
+	return server, msgs
+}
+
+func receive(t *testing.T, c chan wsjtx.Message) wsjtx.Message {
+	t.Helper()
+	select {
+	case msg := <-c:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+		return nil
+	}
+}
+
+func TestRelay(t *testing.T) {
+	gridTracker, gridTrackerMsgs := listening(t)
+	logger, loggerMsgs := listening(t)
+
+	upstream, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Shutdown(context.Background())
+	r, err := New(upstream, []Destination{
+		{Name: "GridTracker", Addr: gridTracker.LocalAddr().(*net.UDPAddr), Allow: []string{"Reply"}},
+		{Name: "logger", Addr: logger.LocalAddr().(*net.UDPAddr)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 10)
+	r.OnError(func(err error) { errs <- err })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ran := make(chan error)
+	go func() { ran <- r.Run(ctx) }()
+
+	client, err := wsjtx.MakeClientGiven("WSJT-X", net.ParseIP("127.0.0.1"),
+		uint(upstream.LocalAddr().(*net.UDPAddr).Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Shutdown(context.Background())
+	clientMsgs := make(chan wsjtx.Message, 10)
+	go func() { _ = client.Listen(ctx, clientMsgs, nil) }()
+
+	// Every datagram from WSJT-X reaches every destination.
+	for _, msgs := range []chan wsjtx.Message{gridTrackerMsgs, loggerMsgs} {
+		if got := receive(t, msgs); got.Type() != wsjtx.HeartbeatType {
+			t.Errorf("got %v, want a heartbeat", got)
+		}
+	}
+	decode := wsjtx.DecodeMessage{Id: "WSJT-X", New: true, Message: "CQ K0SWE DM79"}
+	if err := client.Decode(decode); err != nil {
+		t.Fatal(err)
+	}
+	for _, msgs := range []chan wsjtx.Message{gridTrackerMsgs, loggerMsgs} {
+		if got := receive(t, msgs); got != decode {
+			t.Errorf("got %v, want %v", got, decode)
+		}
+	}
+
+	// An allowed message is passed back to WSJT-X; one which isn't allowed is dropped. Heartbeats
+	// are dropped without an error, although no destination is allowed to send them.
+	if err := logger.Heartbeat(wsjtx.HeartbeatMessage{Id: "WSJT-X", MaxSchema: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.HaltTx(wsjtx.HaltTxMessage{Id: "WSJT-X"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, NotAllowedError) || !strings.Contains(err.Error(), "HaltTx") {
+			t.Errorf("err = %v, want NotAllowedError for HaltTx", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	reply := wsjtx.ReplyMessage{Id: "WSJT-X", Message: "CQ K0SWE DM79"}
+	if err := gridTracker.Reply(reply); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, clientMsgs); got != reply {
+		t.Errorf("got %v, want %v", got, reply)
+	}
+
+	cancel()
+	if err := <-ran; err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+}
+
+func TestNewRejectsUnknownType(t *testing.T) {
+	server, err := wsjtx.NewServer(wsjtx.WithAddress(net.ParseIP("127.0.0.1")), wsjtx.WithPort(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown(context.Background())
+	_, err = New(server, []Destination{
+		{Addr: &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 2238}, Allow: []string{"Halt"}},
+	})
+	if err == nil {
+		t.Error("want an error for an unknown message type")
+	}
+}
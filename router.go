@@ -0,0 +1,225 @@
+package wsjtx
+
+import (
+	"log"
+	"sync"
+)
+
+// HandlerFunc handles one message from WSJT-X.
+type HandlerFunc func(Message)
+
+// Middleware wraps a HandlerFunc to add behavior such as logging, filtering or panic recovery.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// Router dispatches messages to handlers registered for their message type. Server embeds a Router
+// and dispatches every message it hears, so handlers can be used alongside or instead of the
+// Listen channels. A Router can also be used on its own, e.g. to test handlers.
+type Router struct {
+	mu            sync.RWMutex
+	handlers      map[MessageType][]HandlerFunc
+	allHandlers   []HandlerFunc
+	errorHandlers []func(error)
+	middleware    []Middleware
+}
+
+// NewRouter creates a Router with no handlers.
+func NewRouter() *Router {
+	return &Router{handlers: map[MessageType][]HandlerFunc{}}
+}
+
+// Use appends middleware which wraps every dispatch. The first middleware given is the outermost.
+func (r *Router) Use(mw ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Handle registers a handler for the given message type.
+func (r *Router) Handle(t MessageType, h HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[t] = append(r.handlers[t], h)
+}
+
+// OnMessage registers a handler for every message type.
+func (r *Router) OnMessage(h HandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.allHandlers = append(r.allHandlers, h)
+}
+
+// OnError registers a handler for errors which happen while listening, such as parse errors.
+func (r *Router) OnError(h func(error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errorHandlers = append(r.errorHandlers, h)
+}
+
+// OnHeartbeat registers a handler for Heartbeat messages.
+func (r *Router) OnHeartbeat(h func(HeartbeatMessage)) {
+	r.Handle(HeartbeatType, func(m Message) { h(m.(HeartbeatMessage)) })
+}
+
+// OnStatus registers a handler for Status messages.
+func (r *Router) OnStatus(h func(StatusMessage)) {
+	r.Handle(StatusType, func(m Message) { h(m.(StatusMessage)) })
+}
+
+// OnDecode registers a handler for Decode messages.
+func (r *Router) OnDecode(h func(DecodeMessage)) {
+	r.Handle(DecodeType, func(m Message) { h(m.(DecodeMessage)) })
+}
+
+// OnClear registers a handler for Clear messages.
+func (r *Router) OnClear(h func(ClearMessage)) {
+	r.Handle(ClearType, func(m Message) { h(m.(ClearMessage)) })
+}
+
+// OnQsoLogged registers a handler for QSO Logged messages.
+func (r *Router) OnQsoLogged(h func(QsoLoggedMessage)) {
+	r.Handle(QsoLoggedType, func(m Message) { h(m.(QsoLoggedMessage)) })
+}
+
+// OnClose registers a handler for Close messages.
+func (r *Router) OnClose(h func(CloseMessage)) {
+	r.Handle(CloseType, func(m Message) { h(m.(CloseMessage)) })
+}
+
+// OnWSPRDecode registers a handler for WSPR Decode messages.
+func (r *Router) OnWSPRDecode(h func(WSPRDecodeMessage)) {
+	r.Handle(WSPRDecodeType, func(m Message) { h(m.(WSPRDecodeMessage)) })
+}
+
+// OnLoggedAdif registers a handler for Logged ADIF messages.
+func (r *Router) OnLoggedAdif(h func(LoggedAdifMessage)) {
+	r.Handle(LoggedAdifType, func(m Message) { h(m.(LoggedAdifMessage)) })
+}
+
+// OnReply registers a handler for Reply messages.
+func (r *Router) OnReply(h func(ReplyMessage)) {
+	r.Handle(ReplyType, func(m Message) { h(m.(ReplyMessage)) })
+}
+
+// OnReplay registers a handler for Replay messages.
+func (r *Router) OnReplay(h func(ReplayMessage)) {
+	r.Handle(ReplayType, func(m Message) { h(m.(ReplayMessage)) })
+}
+
+// OnHaltTx registers a handler for Halt Tx messages.
+func (r *Router) OnHaltTx(h func(HaltTxMessage)) {
+	r.Handle(HaltTxType, func(m Message) { h(m.(HaltTxMessage)) })
+}
+
+// OnFreeText registers a handler for Free Text messages.
+func (r *Router) OnFreeText(h func(FreeTextMessage)) {
+	r.Handle(FreeTextType, func(m Message) { h(m.(FreeTextMessage)) })
+}
+
+// OnLocation registers a handler for Location messages.
+func (r *Router) OnLocation(h func(LocationMessage)) {
+	r.Handle(LocationType, func(m Message) { h(m.(LocationMessage)) })
+}
+
+// OnHighlightCallsign registers a handler for Highlight Callsign messages.
+func (r *Router) OnHighlightCallsign(h func(HighlightCallsignMessage)) {
+	r.Handle(HighlightCallsignType, func(m Message) { h(m.(HighlightCallsignMessage)) })
+}
+
+// OnSwitchConfiguration registers a handler for Switch Configuration messages.
+func (r *Router) OnSwitchConfiguration(h func(SwitchConfigurationMessage)) {
+	r.Handle(SwitchConfigurationType, func(m Message) { h(m.(SwitchConfigurationMessage)) })
+}
+
+// OnConfigure registers a handler for Configure messages.
+func (r *Router) OnConfigure(h func(ConfigureMessage)) {
+	r.Handle(ConfigureType, func(m Message) { h(m.(ConfigureMessage)) })
+}
+
+// OnAnnotationInfo registers a handler for Annotation Info messages.
+func (r *Router) OnAnnotationInfo(h func(AnnotationInfoMessage)) {
+	r.Handle(AnnotationInfoType, func(m Message) { h(m.(AnnotationInfoMessage)) })
+}
+
+// Dispatch runs the middleware chain and then every handler registered for the message's type,
+// followed by the OnMessage handlers.
+func (r *Router) Dispatch(m Message) {
+	r.mu.RLock()
+	middleware := r.middleware
+	r.mu.RUnlock()
+
+	h := r.dispatch
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	h(m)
+}
+
+func (r *Router) dispatch(m Message) {
+	r.mu.RLock()
+	handlers := r.handlers[m.Type()]
+	allHandlers := r.allHandlers
+	r.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(m)
+	}
+	for _, h := range allHandlers {
+		h(m)
+	}
+}
+
+// DispatchError passes the error to every OnError handler.
+func (r *Router) DispatchError(err error) {
+	r.mu.RLock()
+	errorHandlers := r.errorHandlers
+	r.mu.RUnlock()
+
+	for _, h := range errorHandlers {
+		h(err)
+	}
+}
+
+// Logging is middleware which logs every message before passing it on. A nil logger means the
+// standard logger.
+func Logging(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(m Message) {
+			logger.Printf("%s from %s: %v", m.Type(), m.ClientId(), m)
+			next(m)
+		}
+	}
+}
+
+// Filter is middleware which only passes on messages for which keep returns true.
+func Filter(keep func(Message) bool) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(m Message) {
+			if keep(m) {
+				next(m)
+			}
+		}
+	}
+}
+
+// Recover is middleware which stops a panicking handler from taking down the listener. The panic
+// value is passed to onPanic, or logged if onPanic is nil.
+func Recover(onPanic func(m Message, v interface{})) Middleware {
+	if onPanic == nil {
+		onPanic = func(m Message, v interface{}) {
+			log.Printf("recovered from panic handling %s: %v", m.Type(), v)
+		}
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(m Message) {
+			defer func() {
+				if v := recover(); v != nil {
+					onPanic(m, v)
+				}
+			}()
+			next(m)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package wsjtx
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouterDispatch(t *testing.T) {
+	r := NewRouter()
+	var decodes []DecodeMessage
+	var statuses []StatusMessage
+	var all []MessageType
+	r.OnDecode(func(m DecodeMessage) { decodes = append(decodes, m) })
+	r.OnStatus(func(m StatusMessage) { statuses = append(statuses, m) })
+	r.OnMessage(func(m Message) { all = append(all, m.Type()) })
+
+	r.Dispatch(DecodeMessage{Id: "WSJT-X", Message: "CQ K0SWE DM79"})
+	r.Dispatch(HeartbeatMessage{Id: "WSJT-X"})
+	r.Dispatch(StatusMessage{Id: "WSJT-X", Mode: "FT8"})
+
+	if want := []DecodeMessage{{Id: "WSJT-X", Message: "CQ K0SWE DM79"}}; !reflect.DeepEqual(decodes, want) {
+		t.Errorf("decodes = %v, want %v", decodes, want)
+	}
+	if want := []StatusMessage{{Id: "WSJT-X", Mode: "FT8"}}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+	if want := []MessageType{DecodeType, HeartbeatType, StatusType}; !reflect.DeepEqual(all, want) {
+		t.Errorf("all = %v, want %v", all, want)
+	}
+}
+
+func TestRouterInMessages(t *testing.T) {
+	r := NewRouter()
+	var replies []ReplyMessage
+	var configures []ConfigureMessage
+	r.OnReply(func(m ReplyMessage) { replies = append(replies, m) })
+	r.OnConfigure(func(m ConfigureMessage) { configures = append(configures, m) })
+
+	r.Dispatch(ReplyMessage{Id: "WSJT-X", Message: "CQ K0SWE DM79"})
+	r.Dispatch(ConfigureMessage{Id: "WSJT-X", Mode: "FT4"})
+	r.Dispatch(HaltTxMessage{Id: "WSJT-X"})
+
+	if want := []ReplyMessage{{Id: "WSJT-X", Message: "CQ K0SWE DM79"}}; !reflect.DeepEqual(replies, want) {
+		t.Errorf("replies = %v, want %v", replies, want)
+	}
+	if want := []ConfigureMessage{{Id: "WSJT-X", Mode: "FT4"}}; !reflect.DeepEqual(configures, want) {
+		t.Errorf("configures = %v, want %v", configures, want)
+	}
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	r := NewRouter()
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(m Message) {
+				order = append(order, name)
+				next(m)
+			}
+		}
+	}
+	r.Use(trace("outer"), trace("inner"))
+	r.Use(Filter(func(m Message) bool { return m.ClientId() == "rig1" }))
+	r.OnMessage(func(m Message) { order = append(order, "handler:"+m.ClientId()) })
+
+	r.Dispatch(ClearMessage{Id: "rig1"})
+	r.Dispatch(ClearMessage{Id: "rig2"})
+
+	want := []string{"outer", "inner", "handler:rig1", "outer", "inner"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestRouterRecover(t *testing.T) {
+	r := NewRouter()
+	var recovered interface{}
+	r.Use(Recover(func(m Message, v interface{}) { recovered = v }))
+	r.OnClose(func(CloseMessage) { panic("boom") })
+
+	r.Dispatch(CloseMessage{Id: "WSJT-X"})
+	if recovered != "boom" {
+		t.Errorf("recovered = %v, want boom", recovered)
+	}
+}
+
+func TestRouterLogging(t *testing.T) {
+	r := NewRouter()
+	var buf bytes.Buffer
+	r.Use(Logging(log.New(&buf, "", 0)))
+	r.Dispatch(ReplayMessage{Id: "WSJT-X"})
+	if got := buf.String(); !strings.HasPrefix(got, "Replay from WSJT-X") {
+		t.Errorf("logged %q", got)
+	}
+}
+
+func TestRouterDispatchError(t *testing.T) {
+	r := NewRouter()
+	var got error
+	r.OnError(func(err error) { got = err })
+	r.DispatchError(ParseError)
+	if !errors.Is(got, ParseError) {
+		t.Errorf("got %v, want %v", got, ParseError)
+	}
+}
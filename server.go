@@ -1,178 +1,460 @@
 package wsjtx
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
-	"runtime"
+	"os"
+	"sync"
+	"time"
 )
 
 const magic = 0xadbccbda
-const schema = 2
+
+// The WSJT-X protocol schema numbers this library speaks. Schema 1 is Qt 5.0's QDataStream format,
+// 2 is Qt 5.2's and 3 is Qt 5.4's. defaultSchema is used with clients which haven't negotiated yet.
+const minSchema = 1
+const maxSchema = 3
+const defaultSchema = 2
+
 const qDataStreamNull = 0xffffffff
-const bufLen = 1024
 const localhostAddr = "127.0.0.1"
 const multicastAddr = "224.0.0.1"
 const wsjtxPort = 2237
 
+// Server communicates with one or more WSJT-X instances. Every message sent to WSJT-X is routed to
+// the instance whose Id matches the message's Id field. Messages heard from WSJT-X are dispatched
+// to the handlers registered on the embedded Router as well as sent on the Listen channel.
 type Server struct {
 	ServingAddr net.Addr
 	conn        *net.UDPConn
-	remoteAddr  *net.UDPAddr
-	listening   bool
+	clients     *clientRegistry
+	run         *runState
+	config      serverConfig
+	buffers     *datagramBuffers
+	datagrams   *datagramHandlers
+	*Router
+}
+
+// DatagramHandler is called with every datagram a Server or Client receives, before its message
+// is dispatched, along with the sender and the message parsed from it. The message is nil if the
+// datagram couldn't be parsed. In Lenient mode, trailing holds any bytes after the known fields of
+// the message, e.g. fields added by a newer version of WSJT-X; it's nil otherwise. The datagram is
+// a copy, shared by the handlers, which may keep it; trailing is the end of it.
+type DatagramHandler func(datagram []byte, from *net.UDPAddr, msg Message, trailing []byte)
+
+// datagramHandlers is shared by pointer so that copies of a Server all see the same handlers.
+type datagramHandlers struct {
+	mu       sync.RWMutex
+	handlers []DatagramHandler
 }
 
 var NotConnectedError = fmt.Errorf("haven't heard from wsjtx yet, don't know where to send commands")
 
 // MakeServer creates a multicast UDP connection to communicate with WSJT-X on the default address
-// and port.
+// and port. Except on Windows, that's the multicast group 224.0.0.1, on whichever interface the
+// system chooses.
+//
+// Deprecated: use NewServer, which listens on the same address and port by default.
 func MakeServer() (Server, error) {
-	var defaultWsjtxAddr net.IP
-	switch runtime.GOOS {
-	case "windows":
-		defaultWsjtxAddr = net.ParseIP(localhostAddr)
-	default:
-		defaultWsjtxAddr = net.ParseIP(multicastAddr)
-	}
-	return MakeServerGiven(defaultWsjtxAddr, wsjtxPort)
+	return makeServer()
 }
 
 // MakeServerGiven creates a UDP connection to communicate with WSJT-X on the given address and
 // port. Port 0 is allowed, and will cause the OS to assign a port number.
+//
+// Deprecated: use NewServer with WithAddress and WithPort.
 func MakeServerGiven(ipAddr net.IP, port uint) (Server, error) {
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%v:%d", ipAddr, port))
-	if err != nil {
-		return Server{}, err
-	}
-	var conn *net.UDPConn
-	if ipAddr.IsMulticast() {
-		conn, err = net.ListenMulticastUDP(addr.Network(), nil, addr)
-	} else {
-		conn, err = net.ListenUDP(addr.Network(), addr)
-	}
+	return makeServer(WithAddress(ipAddr), WithPort(port))
+}
+
+// makeServer is NewServer for the older constructors, which return a Server rather than a pointer.
+func makeServer(opts ...Option) (Server, error) {
+	s, err := NewServer(opts...)
 	if err != nil {
 		return Server{}, err
 	}
-	if conn == nil {
-		return Server{}, errors.New("wsjtx udp connection not opened")
+	return *s, nil
+}
+
+func newServer(conn *net.UDPConn, config serverConfig) Server {
+	return Server{
+		ServingAddr: conn.LocalAddr(),
+		conn:        conn,
+		clients:     newClientRegistry(config.maxSchema),
+		run:         &runState{},
+		config:      config,
+		buffers:     newDatagramBuffers(config.datagramSize),
+		datagrams:   &datagramHandlers{},
+		Router:      NewRouter(),
 	}
-	return Server{conn.LocalAddr(), conn, nil, false}, nil
 }
 
 func (s *Server) LocalAddr() net.Addr {
 	return s.conn.LocalAddr()
 }
 
-// ListenToWsjtx listens for messages from WSJT-X. When heard, the messages are parsed and then
-// placed in the given message channel. If parsing errors occur, those are reported on the errors
-// channel. If a fatal error happens, e.g. the network connection gets closed, the channels are
-// closed and the goroutine ends.
-func (s *Server) ListenToWsjtx(c chan interface{}, e chan error) {
-	s.listening = true
-	defer close(c)
-	defer close(e)
+// Listen listens for messages from WSJT-X until the context is cancelled or the server is shut
+// down. When heard, the messages are parsed, dispatched to the Router's handlers and then placed
+// in the given message channel. If parsing errors occur, those are reported on the errors channel
+// and to the OnError handlers. If a fatal error happens, e.g. the network connection fails, it is
+// reported the same way and returned.
+//
+// While listening, the server sends heartbeats and checks that clients are alive if it has been
+// set up to with SetHeartbeatInterval and SetLiveness or the equivalent options. If a read timeout
+// was set with WithReadTimeout, a ReadTimeoutError is reported each time it passes in silence.
+//
+// Either channel may be nil, e.g. when only handlers are used. Channels which aren't nil are
+// always closed when Listen returns. Listen returns nil if it was stopped by the context or by
+// Shutdown. Only one Listen may run at a time, but Listen may be called again with new channels
+// after the previous one has returned.
+func (s *Server) Listen(ctx context.Context, c chan<- Message, e chan<- error) error {
+	if c != nil {
+		defer close(c)
+	}
+	if e != nil {
+		defer close(e)
+	}
+
+	if s.conn == nil || s.run == nil {
+		err := errors.New("wsjtx connection is nil")
+		s.report(ctx, e, err)
+		return err
+	}
+	runCtx, finish, err := s.run.start(ctx)
+	if err != nil {
+		s.report(ctx, e, err)
+		return err
+	}
+	defer finish()
+	ctx, stop, err := unblockReads(runCtx, s.conn)
+	if err != nil {
+		s.report(runCtx, e, err)
+		return err
+	}
+	heartbeating, checking := closedChan, closedChan
+	k := s.config.keepAlive
+	if k.heartbeatInterval > 0 {
+		heartbeating = every(ctx, k.heartbeatInterval, func() {
+			for _, err := range s.heartbeatAll() {
+				s.report(ctx, e, err)
+			}
+		})
+	}
+	if k.livenessInterval > 0 && (k.staleAfter > 0 || k.disconnectAfter > 0) {
+		checking = every(ctx, k.livenessInterval, func() {
+			for _, err := range s.clients.sweep(s.config.now(),
+				time.Duration(k.staleAfter)*k.livenessInterval,
+				time.Duration(k.disconnectAfter)*k.livenessInterval) {
+				s.report(ctx, e, err)
+			}
+		})
+	}
+	defer func() {
+		stop()
+		<-heartbeating
+		<-checking
+	}()
 
+	parser := NewParser(s.config.parseMode)
 	for {
-		b := make([]byte, bufLen)
-		if s.conn == nil {
-			e <- errors.New("wsjtx connection is nil")
-			s.listening = false
-			return
+		if s.config.readTimeout > 0 {
+			if err := s.conn.SetReadDeadline(time.Now().Add(s.config.readTimeout)); err != nil {
+				s.report(ctx, e, err)
+				return err
+			}
+			// Checked after setting the deadline, so one set by unblockReads can't be overwritten.
+			if ctx.Err() != nil {
+				return nil
+			}
 		}
-		length, rAddr, err := s.conn.ReadFromUDP(b)
+		b, length, rAddr, err := s.buffers.read(s.conn)
 		if err != nil {
-			e <- fmt.Errorf("problem reading from wsjtx: %w", err)
-			s.listening = false
-			return
+			s.buffers.put(b)
+			if ctx.Err() != nil || s.run.closed() {
+				return nil
+			}
+			switch {
+			case errors.Is(err, DatagramTruncatedError):
+			case s.config.readTimeout > 0 && errors.Is(err, os.ErrDeadlineExceeded):
+				err = fmt.Errorf("%w for %v", ReadTimeoutError, s.config.readTimeout)
+			default:
+				err = fmt.Errorf("problem reading from wsjtx: %w", err)
+				s.report(ctx, e, err)
+				return err
+			}
+			if !s.report(ctx, e, err) {
+				return nil
+			}
+			continue
 		}
-		s.remoteAddr = rAddr
-		message, err := parseMessage(b, length)
-		if err != nil {
-			e <- err
+		message, trailing, err := parser.Parse((*b)[:length])
+		carryOn := err == nil || s.report(ctx, e, err)
+		if carryOn {
+			s.datagrams.dispatch((*b)[:length], rAddr, message, trailing)
+		}
+		// The message doesn't refer to the buffer, so it can be reused already.
+		s.buffers.put(b)
+		if !carryOn {
+			return nil
 		}
 		if message != nil {
-			c <- message
+			err := s.clients.observe(message, parser.schema, rAddr, s.config.now())
+			if err != nil && !s.report(ctx, e, err) {
+				return nil
+			}
+			s.Dispatch(message)
+			if c == nil {
+				continue
+			}
+			select {
+			case c <- message:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// Serve starts listening in a new goroutine, as Listen does, on channels it makes with the sizes
+// set by WithChannelSizes. Both channels are closed when listening stops.
+func (s *Server) Serve(ctx context.Context) (<-chan Message, <-chan error) {
+	c := make(chan Message, s.config.messageBuffer)
+	e := make(chan error, s.config.errorBuffer)
+	go func() { _ = s.Listen(ctx, c, e) }()
+	return c, e
+}
+
+// ListenToWsjtx listens for messages from WSJT-X until the server is shut down or a fatal error
+// happens. It's equivalent to Listen with a background context.
+func (s *Server) ListenToWsjtx(c chan Message, e chan error) {
+	_ = s.Listen(context.Background(), c, e)
+}
+
+// report passes the error to the OnError handlers and sends it on the errors channel, giving up
+// if the context is done first. It returns false if it gave up.
+func (s *Server) report(ctx context.Context, e chan<- error, err error) bool {
+	if s.Router != nil {
+		s.DispatchError(err)
+	}
+	if e == nil {
+		return true
+	}
+	select {
+	case e <- err:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SetParseMode chooses how strictly messages from WSJT-X are parsed. Servers parse leniently by
+// default, delivering messages of unknown types as UnknownMessage and ignoring fields added by
+// newer versions of WSJT-X. It must be called before Listen.
+func (s *Server) SetParseMode(mode ParseMode) {
+	s.config.parseMode = mode
+}
+
+// SetHeartbeatInterval makes the server send a heartbeat to every client it knows about at the
+// given interval while listening, as WSJT-X's own message server does. Zero, the default, turns
+// that off. It must be called before Listen.
+func (s *Server) SetHeartbeatInterval(d time.Duration) {
+	s.config.heartbeatInterval = d
+}
+
+// SetVersion sets the version and revision advertised in the server's automatic heartbeats. It must
+// be called before Listen.
+func (s *Server) SetVersion(version, revision string) {
+	s.config.version, s.config.revision = version, revision
+}
+
+// SetLiveness makes the server check that clients are still alive while listening. WSJT-X sends a
+// heartbeat every 15 seconds, so that is the usual interval. A client which hasn't been heard from
+// for staleAfter intervals is marked stale and a ClientStale event is sent; if it's heard from
+// again a ClientRevived event follows. A client which hasn't been heard from for disconnectAfter
+// intervals is forgotten and a ClientDisconnected event is sent, as if it had closed. Zero turns
+// either check off; both are off by default. It must be called before Listen.
+func (s *Server) SetLiveness(interval time.Duration, staleAfter, disconnectAfter int) {
+	s.config.livenessInterval = interval
+	s.config.staleAfter = staleAfter
+	s.config.disconnectAfter = disconnectAfter
+}
+
+// heartbeatAll sends a heartbeat to every known client, returning any errors.
+func (s *Server) heartbeatAll() []error {
+	var errs []error
+	for _, client := range s.clients.list() {
+		err := s.Heartbeat(HeartbeatMessage{
+			Id:        client.Id,
+			MaxSchema: s.config.maxSchema,
+			Version:   s.config.version,
+			Revision:  s.config.revision,
+		})
+		if err != nil {
+			errs = append(errs, err)
 		}
 	}
+	return errs
+}
+
+// OnDatagram registers a handler for the raw datagrams the server receives, e.g. to relay them
+// unchanged or to see the trailing bytes of messages from newer versions of WSJT-X.
+func (s *Server) OnDatagram(h DatagramHandler) {
+	s.datagrams.mu.Lock()
+	defer s.datagrams.mu.Unlock()
+	s.datagrams.handlers = append(s.datagrams.handlers, h)
+}
+
+func (d *datagramHandlers) dispatch(
+	datagram []byte, from *net.UDPAddr, msg Message, trailing []byte) {
+	d.mu.RLock()
+	handlers := d.handlers
+	d.mu.RUnlock()
+	if len(handlers) == 0 {
+		return
+	}
+	datagram = append([]byte(nil), datagram...)
+	if trailing != nil {
+		trailing = datagram[len(datagram)-len(trailing):]
+	}
+	for _, h := range handlers {
+		h(datagram, from, msg, trailing)
+	}
 }
 
-// Listening returns whether the ListenToWsjtx goroutine is currently running.
+// Listening returns whether a Listen goroutine is currently running.
 func (s *Server) Listening() bool {
-	return s.listening
+	return s.run.running()
+}
+
+// Shutdown stops the listener, if one is running, and closes the server's socket. It waits until
+// the listener has returned or the context is done, whichever comes first. The server can't be
+// used again afterward. Calling Shutdown more than once is harmless.
+func (s *Server) Shutdown(ctx context.Context) error {
+	done, first := s.run.stop()
+	var err error
+	if first {
+		err = s.conn.Close()
+		s.clients.close()
+	}
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Clients returns the WSJT-X instances which have been heard from and haven't closed, ordered by
+// Id.
+func (s *Server) Clients() []ClientInfo {
+	return s.clients.list()
+}
+
+// Client returns the WSJT-X instance with the given Id, if it has been heard from.
+func (s *Server) Client(id string) (ClientInfo, bool) {
+	return s.clients.lookup(id)
+}
+
+// WatchClients registers a channel which will receive an event whenever a WSJT-X instance appears
+// or goes away, or goes stale and revives if liveness is checked. Events are sent from a goroutine
+// of the channel's own, until Shutdown; if it falls too far behind, later events are dropped and a
+// ClientEventDroppedError is reported instead.
+func (s *Server) WatchClients(c chan<- ClientEvent) {
+	s.clients.watch(c)
 }
 
 // Heartbeat sends a heartbeat message to WSJT-X.
 func (s *Server) Heartbeat(msg HeartbeatMessage) error {
-	msgBytes, _ := encodeHeartbeat(msg)
-	return s.tryWrite(msgBytes)
+	return s.Send(msg)
 }
 
 // Clear sends a message to WSJT-X to clear the band activity window, the RX frequency window, or
 // both.
 func (s *Server) Clear(msg ClearMessage) error {
-	msgBytes, _ := encodeClear(msg)
-	return s.tryWrite(msgBytes)
+	return s.Send(msg)
 }
 
 // Reply initiates a reply to an earlier decode. The decode message must have started with CQ or
 // QRZ.
 func (s *Server) Reply(msg ReplyMessage) error {
-	msgBytes, _ := encodeReply(msg)
-	return s.tryWrite(msgBytes)
+	return s.Send(msg)
 }
 
 // Close sends a message to WSJT-X to close the program.
+//
+// Deprecated: despite the name, this doesn't close the Server. Use CloseClient to ask WSJT-X to
+// close, and Shutdown to stop the Server.
 func (s *Server) Close(msg CloseMessage) error {
-	msgBytes, _ := encodeClose(msg)
-	return s.tryWrite(msgBytes)
+	return s.CloseClient(msg)
+}
+
+// CloseClient sends a message to WSJT-X to close the program.
+func (s *Server) CloseClient(msg CloseMessage) error {
+	return s.Send(msg)
 }
 
 // Replay sends a message to WSJT-X to replay QSOs in the Band Activity window.
 func (s *Server) Replay(msg ReplayMessage) error {
-	msgBytes, _ := encodeReplay(msg)
-	return s.tryWrite(msgBytes)
+	return s.Send(msg)
 }
 
 // HaltTx sends a message to WSJT-X to halt transmission.
 func (s *Server) HaltTx(msg HaltTxMessage) error {
-	msgBytes, _ := encodeHaltTx(msg)
-	return s.tryWrite(msgBytes)
+	return s.Send(msg)
 }
 
 // FreeText sends a message to WSJT-X to set the free text of the TX message.
 func (s *Server) FreeText(msg FreeTextMessage) error {
-	msgBytes, _ := encodeFreeText(msg)
-	return s.tryWrite(msgBytes)
+	return s.Send(msg)
 }
 
 // Location sends a message to WSJT-X to set this station's Maidenhead grid.
 func (s *Server) Location(msg LocationMessage) error {
-	msgBytes, _ := encodeLocation(msg)
-	return s.tryWrite(msgBytes)
+	return s.Send(msg)
 }
 
 // HighlightCallsign sends a message to WSJT-X to set callsign highlighting.
 func (s *Server) HighlightCallsign(msg HighlightCallsignMessage) error {
-	msgBytes, _ := encodeHighlightCallsign(msg)
-	return s.tryWrite(msgBytes)
+	return s.Send(msg)
 }
 
 // SwitchConfiguration sends a message to WSJT-X to switch to a different pre-defined configuration.
 func (s *Server) SwitchConfiguration(msg SwitchConfigurationMessage) error {
-	msgBytes, _ := encodeSwitchConfiguration(msg)
-	return s.tryWrite(msgBytes)
+	return s.Send(msg)
 }
 
 // Configure sends a message to WSJT-X to change various configuration options.
 func (s *Server) Configure(msg ConfigureMessage) error {
-	msgBytes, _ := encodeConfigure(msg)
-	return s.tryWrite(msgBytes)
+	return s.Send(msg)
+}
+
+// AnnotationInfo sends a message to WSJT-X to annotate a DX call, e.g. with its sort order in the
+// Fox mode caller queue. Only WSJT-X 2.7 and later understand this message.
+func (s *Server) AnnotationInfo(msg AnnotationInfoMessage) error {
+	return s.Send(msg)
+}
+
+// Send encodes the message with the schema negotiated with its client and sends it to the WSJT-X
+// instance whose Id matches the message's Id. A message which can't be encoded, e.g. because a
+// field is out of range, isn't sent; the error wraps EncodeError, and is a *FieldError if it's
+// about a field.
+func (s *Server) Send(msg Message) error {
+	client, ok := s.clients.lookup(msg.ClientId())
+	if !ok {
+		return fmt.Errorf("%w: no client with id %q", NotConnectedError, msg.ClientId())
+	}
+	return send(s.conn, client.Addr, msg, client.Schema)
 }
 
-func (s *Server) tryWrite(msgBytes []byte) error {
-	if s.remoteAddr == nil {
-		return NotConnectedError
+// SendDatagram sends an already encoded message, unchanged, to the WSJT-X instance with the given
+// Id, e.g. to pass on a message from another server.
+func (s *Server) SendDatagram(id string, datagram []byte) error {
+	client, ok := s.clients.lookup(id)
+	if !ok {
+		return fmt.Errorf("%w: no client with id %q", NotConnectedError, id)
 	}
-	_, err := s.conn.WriteTo(msgBytes, s.remoteAddr)
+	_, err := s.conn.WriteTo(datagram, client.Addr)
 	return err
 }
@@ -0,0 +1,21 @@
+//go:build aix || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+
+package wsjtx
+
+import "syscall"
+
+// The BSDs take bytes for the IPv4 multicast options, and the interface by address.
+func setIPv4MulticastSockopts(s int, o multicastSockopts) error {
+	const level = syscall.IPPROTO_IP
+	if err := syscall.SetsockoptByte(s, level, syscall.IP_MULTICAST_TTL, byte(o.ttl)); err != nil {
+		return err
+	}
+	loop := byte(boolInt(o.loopback))
+	if err := syscall.SetsockoptByte(s, level, syscall.IP_MULTICAST_LOOP, loop); err != nil {
+		return err
+	}
+	if o.ifIndex == 0 {
+		return nil
+	}
+	return syscall.SetsockoptInet4Addr(s, level, syscall.IP_MULTICAST_IF, o.ifAddr)
+}
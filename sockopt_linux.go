@@ -0,0 +1,20 @@
+package wsjtx
+
+import "syscall"
+
+// Linux takes ints for the IPv4 multicast options, and the interface by index.
+func setIPv4MulticastSockopts(s int, o multicastSockopts) error {
+	const level = syscall.IPPROTO_IP
+	if err := syscall.SetsockoptInt(s, level, syscall.IP_MULTICAST_TTL, o.ttl); err != nil {
+		return err
+	}
+	loop := boolInt(o.loopback)
+	if err := syscall.SetsockoptInt(s, level, syscall.IP_MULTICAST_LOOP, loop); err != nil {
+		return err
+	}
+	if o.ifIndex == 0 {
+		return nil
+	}
+	mreq := &syscall.IPMreqn{Ifindex: int32(o.ifIndex)}
+	return syscall.SetsockoptIPMreqn(s, level, syscall.IP_MULTICAST_IF, mreq)
+}
@@ -0,0 +1,12 @@
+//go:build !unix && !windows
+
+package wsjtx
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func setMulticastSockopts(fd uintptr, o multicastSockopts) error {
+	return fmt.Errorf("multicast options aren't supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package wsjtx
+
+import "syscall"
+
+func setMulticastSockopts(fd uintptr, o multicastSockopts) error {
+	s := int(fd)
+	if !o.ipv6 {
+		return setIPv4MulticastSockopts(s, o)
+	}
+	const level = syscall.IPPROTO_IPV6
+	if err := syscall.SetsockoptInt(s, level, syscall.IPV6_MULTICAST_HOPS, o.ttl); err != nil {
+		return err
+	}
+	loop := boolInt(o.loopback)
+	if err := syscall.SetsockoptInt(s, level, syscall.IPV6_MULTICAST_LOOP, loop); err != nil {
+		return err
+	}
+	if o.ifIndex == 0 {
+		return nil
+	}
+	return syscall.SetsockoptInt(s, level, syscall.IPV6_MULTICAST_IF, o.ifIndex)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+package wsjtx
+
+import "syscall"
+
+// Windows takes ints for the multicast options, and the IPv4 interface by address.
+func setMulticastSockopts(fd uintptr, o multicastSockopts) error {
+	s := syscall.Handle(fd)
+	level, ttlOpt, loopOpt := syscall.IPPROTO_IP, syscall.IP_MULTICAST_TTL, syscall.IP_MULTICAST_LOOP
+	if o.ipv6 {
+		level, ttlOpt = syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_HOPS
+		loopOpt = syscall.IPV6_MULTICAST_LOOP
+	}
+	if err := syscall.SetsockoptInt(s, level, ttlOpt, o.ttl); err != nil {
+		return err
+	}
+	if err := syscall.SetsockoptInt(s, level, loopOpt, boolInt(o.loopback)); err != nil {
+		return err
+	}
+	if o.ifIndex == 0 {
+		return nil
+	}
+	if o.ipv6 {
+		return syscall.SetsockoptInt(s, level, syscall.IPV6_MULTICAST_IF, o.ifIndex)
+	}
+	return syscall.SetsockoptInet4Addr(s, level, syscall.IP_MULTICAST_IF, o.ifAddr)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
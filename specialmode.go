@@ -0,0 +1,44 @@
+package wsjtx
+
+import "fmt"
+
+// SpecialOperationMode is the contest or DXpedition mode WSJT-X is in, which changes the messages
+// it sends. It's set in WSJT-X's Settings, Advanced tab.
+type SpecialOperationMode uint8
+
+const (
+	SpecialOperationNone        SpecialOperationMode = 0
+	SpecialOperationNAVHF       SpecialOperationMode = 1 // NA VHF Contest
+	SpecialOperationEUVHF       SpecialOperationMode = 2 // EU VHF Contest
+	SpecialOperationFieldDay    SpecialOperationMode = 3 // ARRL Field Day
+	SpecialOperationRTTYRoundup SpecialOperationMode = 4 // ARRL RTTY Roundup
+	SpecialOperationWWDigi      SpecialOperationMode = 5 // WW Digi DX Contest
+	SpecialOperationFox         SpecialOperationMode = 6 // DXpedition, as the Fox
+	SpecialOperationHound       SpecialOperationMode = 7 // DXpedition, calling the Fox
+	SpecialOperationARRLDigi    SpecialOperationMode = 8 // ARRL International Digital Contest
+)
+
+// The names WSJT-X uses for the special operation modes.
+var specialOperationModeNames = map[SpecialOperationMode]string{
+	SpecialOperationNone:        "NONE",
+	SpecialOperationNAVHF:       "NA VHF",
+	SpecialOperationEUVHF:       "EU VHF",
+	SpecialOperationFieldDay:    "FIELD DAY",
+	SpecialOperationRTTYRoundup: "RTTY RU",
+	SpecialOperationWWDigi:      "WW DIGI",
+	SpecialOperationFox:         "FOX",
+	SpecialOperationHound:       "HOUND",
+	SpecialOperationARRLDigi:    "ARRL DIGI",
+}
+
+func (m SpecialOperationMode) String() string {
+	if name, ok := specialOperationModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("SpecialOperationMode(%d)", uint8(m))
+}
+
+// Valid reports whether the mode is one WSJT-X knows.
+func (m SpecialOperationMode) Valid() bool {
+	return m <= SpecialOperationARRLDigi
+}
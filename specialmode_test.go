@@ -0,0 +1,24 @@
+package wsjtx
+
+import "testing"
+
+func TestSpecialOperationModeString(t *testing.T) {
+	tests := []struct {
+		mode SpecialOperationMode
+		want string
+	}{
+		{SpecialOperationNone, "NONE"},
+		{SpecialOperationFieldDay, "FIELD DAY"},
+		{SpecialOperationRTTYRoundup, "RTTY RU"},
+		{SpecialOperationARRLDigi, "ARRL DIGI"},
+		{SpecialOperationMode(9), "SpecialOperationMode(9)"},
+	}
+	for _, tt := range tests {
+		if got := tt.mode.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+	if SpecialOperationMode(9).Valid() || !SpecialOperationARRLDigi.Valid() {
+		t.Error("Valid is wrong")
+	}
+}